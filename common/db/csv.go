@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// Exports the result of query as CSV into the writer
+//
+// The first record is the names of columns, then every row is written as a record while iterating.
+// The NULL value is written as empty field, time.Time is formatted by RFC3339,
+// and other values are formatted by fmt.Sprint().
+//
+// The number of rows(excluding header) is returned.
+func (dbController *DbController) ExportCSV(w io.Writer, sqlQuery string, args ...interface{}) (rowsWritten uint) {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	csvWriter := csv.NewWriter(w)
+
+	var record []string
+	rowsWritten = dbController.queryForRowsContext(
+		context.Background(),
+		func(columns []string) {
+			record = make([]string, len(columns))
+			writeCsvRecord(csvWriter, columns)
+		},
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			for i, value := range ToRowsExt(rows).scanValues() {
+				record[i] = toCsvField(value)
+			}
+			writeCsvRecord(csvWriter, record)
+
+			return IterateContinue
+		}),
+		sqlQuery, args,
+	)
+
+	csvWriter.Flush()
+	PanicIfError(utils.BuildErrorWithCaller(csvWriter.Error()))
+
+	return
+}
+
+func writeCsvRecord(csvWriter *csv.Writer, record []string) {
+	PanicIfError(utils.BuildErrorWithCaller(csvWriter.Write(record)))
+}
+
+func toCsvField(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestCsvSuite struct{}
+
+var _ = Suite(&TestCsvSuite{})
+
+// Tests the exporting of rows as CSV
+func (suite *TestCsvSuite) TestExportCSV(c *C) {
+	testCases := []*struct {
+		values       [][]driver.Value
+		expectedRows uint
+		expectedCsv  string
+	}{
+		{
+			[][]driver.Value{
+				{int64(1), []byte("agent-1"), time.Date(2017, 3, 8, 10, 20, 30, 0, time.UTC), 1.5},
+				{int64(2), "agent, \"quoted\"", nil, nil},
+				{int64(3), "multi\nline", nil, true},
+			},
+			3,
+			"ag_id,ag_name,ag_last_heartbeat,ag_value\n" +
+				"1,agent-1,2017-03-08T10:20:30Z,1.5\n" +
+				"2,\"agent, \"\"quoted\"\"\",,\n" +
+				"3,\"multi\nline\",,true\n",
+		},
+		{
+			[][]driver.Value{},
+			0,
+			"ag_id,ag_name,ag_last_heartbeat,ag_value\n",
+		},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		testedCtrl := newFakeDbController(&fakeDriverDb{
+			queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+				return &fakeRows{
+					columns: []string{"ag_id", "ag_name", "ag_last_heartbeat", "ag_value"},
+					values:  testCase.values,
+				}, nil
+			},
+		})
+
+		output := &bytes.Buffer{}
+		rowsWritten := testedCtrl.ExportCSV(output, "SELECT * FROM nqm_agent WHERE ag_id > ?", 0)
+
+		c.Assert(rowsWritten, Equals, testCase.expectedRows, comment)
+		c.Assert(output.String(), Equals, testCase.expectedCsv, comment)
+
+		testedCtrl.Release()
+	}
+}
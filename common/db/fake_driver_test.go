@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Fake database(as driver.Connector) used to simulate behaviors of driver
+//
+// The execFunc and queryFunc could be set to customize the result of executing.
+type fakeDriverDb struct {
+	lock sync.Mutex
+
+	execFunc  func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Result, error)
+	queryFunc func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error)
+	// Called before the statement is prepared
+	prepareFunc func(query string)
+
+	executedQueries  []string
+	numberOfPrepared map[string]int
+}
+
+func newFakeDbController(fakeDb *fakeDriverDb) *DbController {
+	return NewDbController(sql.OpenDB(fakeDb))
+}
+
+func (fakeDb *fakeDriverDb) Connect(ctx context.Context) (driver.Conn, error) {
+	return &fakeConn{db: fakeDb}, nil
+}
+func (fakeDb *fakeDriverDb) Driver() driver.Driver {
+	return fakeDriver{fakeDb}
+}
+
+func (fakeDb *fakeDriverDb) executed() []string {
+	fakeDb.lock.Lock()
+	defer fakeDb.lock.Unlock()
+
+	return append([]string{}, fakeDb.executedQueries...)
+}
+func (fakeDb *fakeDriverDb) prepared(query string) int {
+	fakeDb.lock.Lock()
+	defer fakeDb.lock.Unlock()
+
+	return fakeDb.numberOfPrepared[query]
+}
+func (fakeDb *fakeDriverDb) record(query string) {
+	fakeDb.lock.Lock()
+	defer fakeDb.lock.Unlock()
+
+	fakeDb.executedQueries = append(fakeDb.executedQueries, query)
+}
+
+type fakeDriver struct {
+	db *fakeDriverDb
+}
+
+func (d fakeDriver) Open(name string) (driver.Conn, error) {
+	return d.db.Connect(context.Background())
+}
+
+type fakeConn struct {
+	db *fakeDriverDb
+	// The context of current executing
+	ctx context.Context
+
+	inTx     bool
+	readOnly bool
+}
+
+func (conn *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	conn.db.lock.Lock()
+	if conn.db.numberOfPrepared == nil {
+		conn.db.numberOfPrepared = make(map[string]int)
+	}
+	conn.db.numberOfPrepared[query]++
+	conn.db.lock.Unlock()
+
+	if conn.db.prepareFunc != nil {
+		conn.db.prepareFunc(query)
+	}
+
+	return &fakeStmt{conn, query}, nil
+}
+func (conn *fakeConn) Close() error {
+	return nil
+}
+func (conn *fakeConn) Begin() (driver.Tx, error) {
+	return conn.BeginTx(context.Background(), driver.TxOptions{})
+}
+func (conn *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	conn.inTx = true
+	conn.readOnly = opts.ReadOnly
+
+	return &fakeTx{conn}, nil
+}
+func (conn *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	conn.db.record(query)
+	conn.ctx = ctx
+
+	if conn.inTx && conn.readOnly {
+		return nil, fmt.Errorf("Cannot execute statement in a READ ONLY transaction")
+	}
+	if conn.db.execFunc != nil {
+		return conn.db.execFunc(conn, query, args)
+	}
+
+	return driver.RowsAffected(0), nil
+}
+func (conn *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	conn.db.record(query)
+	conn.ctx = ctx
+
+	if conn.db.queryFunc != nil {
+		return conn.db.queryFunc(conn, query, args)
+	}
+
+	return &fakeRows{}, nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (stmt *fakeStmt) Close() error {
+	return nil
+}
+func (stmt *fakeStmt) NumInput() int {
+	return -1
+}
+func (stmt *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return stmt.conn.ExecContext(context.Background(), stmt.query, toNamedValues(args))
+}
+func (stmt *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return stmt.conn.QueryContext(context.Background(), stmt.query, toNamedValues(args))
+}
+
+type fakeTx struct {
+	conn *fakeConn
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.conn.inTx = false
+	return nil
+}
+func (tx *fakeTx) Rollback() error {
+	tx.conn.inTx = false
+	return nil
+}
+
+// Rows with fixed values
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+	// The error returned after all of the values instead of io.EOF
+	nextErr error
+
+	index int
+}
+
+func (rows *fakeRows) Columns() []string {
+	return rows.columns
+}
+func (rows *fakeRows) Close() error {
+	return nil
+}
+func (rows *fakeRows) Next(dest []driver.Value) error {
+	if rows.index >= len(rows.values) {
+		if rows.nextErr != nil {
+			return rows.nextErr
+		}
+		return io.EOF
+	}
+
+	copy(dest, rows.values[rows.index])
+	rows.index++
+
+	return nil
+}
+
+func toNamedValues(args []driver.Value) []driver.NamedValue {
+	namedValues := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		namedValues[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+
+	return namedValues
+}
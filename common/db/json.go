@@ -0,0 +1,88 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Builds the value of JSON column, which is marshalled by "encoding/json"
+//
+//	dbController.Exec("INSERT INTO host_meta(hm_id, hm_data) VALUES(?, ?)", id, JSONValue(meta))
+//
+// The nil value(including nil pointer, map, or slice) is stored as NULL.
+func JSONValue(v interface{}) driver.Valuer {
+	return &jsonValue{v}
+}
+
+// Builds the scanner of JSON column, which unmarshals the column into "dest" by "encoding/json"
+//
+//	ToRowsExt(rows).Scan(&id, JSONScan(&meta))
+//
+// The "dest" must be a pointer. The NULL value sets "dest" to zero value of its type,
+// and the malformed JSON is an error of scanning(RowsExt.Scan() panics with it).
+func JSONScan(dest interface{}) sql.Scanner {
+	return &jsonScanner{dest}
+}
+
+type jsonValue struct {
+	v interface{}
+}
+
+func (j *jsonValue) Value() (driver.Value, error) {
+	if isNilValue(j.v) {
+		return nil, nil
+	}
+
+	jsonBytes, err := json.Marshal(j.v)
+	if err != nil {
+		return nil, fmt.Errorf("Marshal value of JSON column has error: %v", err)
+	}
+
+	return string(jsonBytes), nil
+}
+
+type jsonScanner struct {
+	dest interface{}
+}
+
+func (j *jsonScanner) Scan(src interface{}) error {
+	destValue := reflect.ValueOf(j.dest)
+	if destValue.Kind() != reflect.Ptr || destValue.IsNil() {
+		return fmt.Errorf("Needs non-nil pointer to scan JSON column. Got: %T", j.dest)
+	}
+
+	var srcBytes []byte
+	switch srcValue := src.(type) {
+	case nil:
+		destValue.Elem().Set(reflect.Zero(destValue.Elem().Type()))
+		return nil
+	case []byte:
+		srcBytes = srcValue
+	case string:
+		srcBytes = []byte(srcValue)
+	default:
+		return fmt.Errorf("Needs type of \"[]byte\" or string for JSON column. Got: %T", src)
+	}
+
+	if err := json.Unmarshal(srcBytes, j.dest); err != nil {
+		return fmt.Errorf("Unmarshal JSON column has error: %v. JSON: %q", err, srcBytes)
+	}
+
+	return nil
+}
+
+func isNilValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	switch value := reflect.ValueOf(v); value.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return value.IsNil()
+	}
+
+	return false
+}
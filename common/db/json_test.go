@@ -0,0 +1,93 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestJsonSuite struct{}
+
+var _ = Suite(&TestJsonSuite{})
+
+type sampleHostMeta struct {
+	Name   string            `json:"name"`
+	Ports  []int             `json:"ports"`
+	Labels map[string]string `json:"labels"`
+	Owner  *sampleMetaOwner  `json:"owner"`
+}
+type sampleMetaOwner struct {
+	Id   int    `json:"id"`
+	Mail string `json:"mail"`
+}
+
+// Tests the round trip of struct through JSON column
+func (suite *TestJsonSuite) TestRoundTrip(c *C) {
+	var storedValue driver.Value
+	testedCtrl := newFakeDbController(&fakeDriverDb{
+		execFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Result, error) {
+			storedValue = args[0].Value
+			return driver.RowsAffected(1), nil
+		},
+		queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+			return &fakeRows{columns: []string{"hm_data"}, values: [][]driver.Value{{storedValue}}}, nil
+		},
+	})
+	defer testedCtrl.Release()
+
+	sampleMeta := &sampleHostMeta{
+		Name: "host-1", Ports: []int{80, 443},
+		Labels: map[string]string{"idc": "tp-1"},
+		Owner:  &sampleMetaOwner{51, "owner@example.com"},
+	}
+
+	testCases := []*struct {
+		storedMeta   *sampleHostMeta
+		expectedMeta sampleHostMeta
+	}{
+		{sampleMeta, *sampleMeta},
+		{nil, sampleHostMeta{}},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		testedCtrl.Exec("INSERT INTO host_meta(hm_data) VALUES(?)", JSONValue(testCase.storedMeta))
+
+		testedMeta := sampleHostMeta{Name: "to-be-reset"}
+		testedCtrl.QueryForRows(
+			RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+				ToRowsExt(rows).Scan(JSONScan(&testedMeta))
+				return IterateContinue
+			}),
+			"SELECT hm_data FROM host_meta",
+		)
+
+		c.Assert(testedMeta, DeepEquals, testCase.expectedMeta, comment)
+	}
+}
+
+// Tests the scanning of malformed JSON
+func (suite *TestJsonSuite) TestScanMalformedJson(c *C) {
+	testedCtrl := newFakeDbController(&fakeDriverDb{
+		queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+			return &fakeRows{columns: []string{"hm_data"}, values: [][]driver.Value{{[]byte(`{"name": `)}}}, nil
+		},
+	})
+	defer testedCtrl.Release()
+
+	c.Assert(
+		func() {
+			testedCtrl.QueryForRows(
+				RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+					var testedMeta sampleHostMeta
+					ToRowsExt(rows).Scan(JSONScan(&testedMeta))
+					return IterateContinue
+				}),
+				"SELECT hm_data FROM host_meta",
+			)
+		},
+		PanicMatches, ".*Unmarshal JSON column has error.*",
+	)
+}
@@ -0,0 +1,18 @@
+package db
+
+import (
+	"log"
+)
+
+// The logger with formatted printing, which is satisfied by *log.Logger, logrus, etc.
+type PrintfLogger interface {
+	Printf(format string, v ...interface{})
+}
+
+// The logger used by this package(e.g. slow query, dry-run, maintenance)
+//
+// The default one is the standard logger of "log" package,
+// this variable could be replaced to unify the logging with application's logger:
+//
+//	db.Logger = logrus.StandardLogger()
+var Logger PrintfLogger = log.Default()
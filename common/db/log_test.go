@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestLogSuite struct{}
+
+var _ = Suite(&TestLogSuite{})
+
+type capturingLogger struct {
+	lock     sync.Mutex
+	messages []string
+}
+
+func (logger *capturingLogger) Printf(format string, v ...interface{}) {
+	logger.lock.Lock()
+	defer logger.lock.Unlock()
+
+	logger.messages = append(logger.messages, fmt.Sprintf(format, v...))
+}
+
+// Tests the logging through the injected logger
+func (suite *TestLogSuite) TestInjectedLogger(c *C) {
+	logger := &capturingLogger{}
+	Logger = logger
+	defer func() { Logger = log.Default() }()
+
+	testedCtrl := newFakeDbController(&fakeDriverDb{
+		execFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Result, error) {
+			if query == "ANALYZE TABLE no_such_table" {
+				return nil, errors.New("no such table")
+			}
+
+			time.Sleep(2 * time.Millisecond)
+			return driver.RowsAffected(1), nil
+		},
+	})
+	defer testedCtrl.Release()
+
+	/**
+	 * Slow query
+	 */
+	testedCtrl.SetSlowQueryThreshold(time.Millisecond)
+	testedCtrl.Exec("UPDATE nqm_agent SET ag_status = 1")
+	testedCtrl.SetSlowQueryThreshold(0)
+	// :~)
+
+	/**
+	 * Failed statement of maintenance
+	 */
+	c.Assert(
+		func() { testedCtrl.Maintenance(context.Background(), "ANALYZE TABLE no_such_table") },
+		PanicMatches, ".*no such table.*",
+	)
+	// :~)
+
+	c.Assert(logger.messages, HasLen, 3)
+	c.Assert(logger.messages[0], Matches, `\[Slow Query\] .*UPDATE nqm_agent.*`)
+	c.Assert(logger.messages[1], Matches, `\[Maintenance\] \(1/1\) Executing.*`)
+	c.Assert(logger.messages[2], Matches, `\[Maintenance\] \(1/1\) Failed.*no such table`)
+}
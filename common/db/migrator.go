@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// The migration of schema
+type Migration struct {
+	// The version must be consecutive, starting from 1
+	Version int
+	// The SQL of upgrading
+	Up string
+}
+
+// Applies pending migrations of schema, the applied versions are recorded in "schema_migrations" table
+//
+// Every migration is applied in its own transaction with the recording of version.
+// Be aware that DDL statements of MySQL cause implicit commit,
+// so a failed migration with multiple DDL statements could not be rollback entirely.
+type Migrator struct {
+	dbController *DbController
+	migrations   []Migration
+}
+
+const createSchemaMigrationsSql = `
+	CREATE TABLE IF NOT EXISTS schema_migrations(
+		version INT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// Builds a migrator with migrations ordered by version
+func NewMigrator(dbController *DbController, migrations []Migration) *Migrator {
+	return &Migrator{dbController, migrations}
+}
+
+// Gets the current version of schema, 0 means no migration has been applied
+func (migrator *Migrator) CurrentVersion() int {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	migrator.dbController.Exec(createSchemaMigrationsSql)
+
+	var version sql.NullInt64
+	migrator.dbController.QueryForScalar(&version, "SELECT MAX(version) FROM schema_migrations")
+
+	return int(version.Int64)
+}
+
+// Applies the pending migrations and gets the number of applied ones
+//
+// This method is idempotent since the applied migrations are skipped.
+//
+// The panic is raised if:
+//
+//	The versions are not consecutive(e.g., 1, 2, 4) or not ascending
+//	There is a version, not greater than the current one, which has not been applied
+func (migrator *Migrator) Migrate() (numberOfApplied int) {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	migrator.checkVersions()
+
+	currentVersion := migrator.CurrentVersion()
+	for _, migration := range migrator.migrations {
+		if migration.Version <= currentVersion {
+			migrator.checkApplied(migration.Version)
+			continue
+		}
+
+		migrator.apply(migration)
+		numberOfApplied++
+	}
+
+	return
+}
+
+func (migrator *Migrator) checkVersions() {
+	for i, migration := range migrator.migrations {
+		if migration.Version != i+1 {
+			PanicIfError(utils.BuildErrorWithCaller(
+				fmt.Errorf("Version of migration is out of order(expected: %d). Version: %d", i+1, migration.Version),
+			))
+		}
+	}
+}
+
+func (migrator *Migrator) checkApplied(version int) {
+	if !migrator.dbController.Exists("SELECT 1 FROM schema_migrations WHERE version = ?", version) {
+		PanicIfError(utils.BuildErrorWithCaller(
+			fmt.Errorf("Version of migration has not been applied before current version: %d", version),
+		))
+	}
+}
+
+func (migrator *Migrator) apply(migration Migration) {
+	migrator.dbController.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		txExt := ToTxExt(tx)
+		txExt.Exec(migration.Up)
+		txExt.Exec("INSERT INTO schema_migrations(version) VALUES(?)", migration.Version)
+
+		return TxCommit
+	}))
+}
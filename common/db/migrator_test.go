@@ -0,0 +1,83 @@
+package db
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type TestMigratorSuite struct{}
+
+var _ = Suite(&TestMigratorSuite{})
+
+var sampleMigrations = []Migration{
+	{1, "CREATE TABLE mg_car(c_id INT PRIMARY KEY)"},
+	{2, "ALTER TABLE mg_car ADD COLUMN c_name VARCHAR(64)"},
+	{3, "INSERT INTO mg_car VALUES(1, 'car-1')"},
+}
+
+// Tests the migrating of fresh and partially migrated database
+func (suite *TestMigratorSuite) TestMigrate(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	/**
+	 * Fresh database
+	 */
+	c.Assert(NewMigrator(testedCtrl, sampleMigrations[:2]).Migrate(), Equals, 2)
+	c.Assert(NewMigrator(testedCtrl, sampleMigrations).CurrentVersion(), Equals, 2)
+	// :~)
+
+	/**
+	 * Partially migrated database
+	 */
+	c.Assert(NewMigrator(testedCtrl, sampleMigrations).Migrate(), Equals, 1)
+	c.Assert(NewMigrator(testedCtrl, sampleMigrations).CurrentVersion(), Equals, 3)
+	// :~)
+
+	/**
+	 * Idempotent
+	 */
+	c.Assert(NewMigrator(testedCtrl, sampleMigrations).Migrate(), Equals, 0)
+
+	var numberOfCars int
+	testedCtrl.QueryForScalar(&numberOfCars, "SELECT COUNT(*) FROM mg_car")
+	c.Assert(numberOfCars, Equals, 1)
+	// :~)
+}
+
+// Tests the refusing of versions with gap or out of order
+func (suite *TestMigratorSuite) TestMigrateWithInvalidVersions(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testCases := [][]Migration{
+		{sampleMigrations[0], sampleMigrations[2]},
+		{sampleMigrations[1], sampleMigrations[0]},
+	}
+
+	for i, testCase := range testCases {
+		c.Assert(
+			func() { NewMigrator(testedCtrl, testCase).Migrate() },
+			PanicMatches, ".*out of order.*",
+			Commentf("Test Case: %d", i+1),
+		)
+	}
+
+	c.Assert(NewMigrator(testedCtrl, nil).CurrentVersion(), Equals, 0)
+}
+
+// Tests the rollback of failed migration
+func (suite *TestMigratorSuite) TestMigrateWithFailure(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	migrations := []Migration{
+		sampleMigrations[0],
+		{2, "ALTER TABLE no_such_table ADD COLUMN c_name VARCHAR(64)"},
+	}
+
+	c.Assert(
+		func() { NewMigrator(testedCtrl, migrations).Migrate() },
+		PanicMatches, ".*no_such_table.*",
+	)
+	c.Assert(NewMigrator(testedCtrl, migrations).CurrentVersion(), Equals, 1)
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	mysqlErrDuplicateKey    = 1062
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+
+	// "MySQL server has gone away" and "Lost connection to MySQL server during query"
+	mysqlErrServerGone = 2006
+	mysqlErrServerLost = 2013
+)
+
+// Gets the error number of MySQL from the chain of error
+//
+// The false value is returned if there is no *mysql.MySQLError in the chain.
+func MySQLErrorCode(err error) (uint16, bool) {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return 0, false
+	}
+
+	return mysqlErr.Number, true
+}
+
+// Checks whether or not the error is "Duplicate entry"(1062) of MySQL
+func IsDuplicateKeyError(err error) bool {
+	code, ok := MySQLErrorCode(err)
+	return ok && code == mysqlErrDuplicateKey
+}
+
+func isMySQLRetryableError(err error) bool {
+	code, ok := MySQLErrorCode(err)
+	if !ok {
+		return false
+	}
+
+	switch code {
+	case mysqlErrDeadlock, mysqlErrLockWaitTimeout:
+		return true
+	}
+
+	return false
+}
+
+func isMySQLConnectionError(err error) bool {
+	if errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+
+	code, ok := MySQLErrorCode(err)
+	return ok && (code == mysqlErrServerGone || code == mysqlErrServerLost)
+}
+
+// Replaces the password in DSN of MySQL with "***"
+//
+// The whole DSN is hidden if it cannot be parsed, since the position of password is unknown.
+func redactMySQLDsn(dsn string) string {
+	mysqlConfig, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "<invalid DSN of MySQL>"
+	}
+
+	if mysqlConfig.Passwd != "" {
+		mysqlConfig.Passwd = "***"
+	}
+
+	return mysqlConfig.FormatDSN()
+}
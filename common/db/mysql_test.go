@@ -0,0 +1,42 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Cepave/open-falcon-backend/common/utils"
+	"github.com/go-sql-driver/mysql"
+	. "gopkg.in/check.v1"
+)
+
+type TestMysqlSuite struct{}
+
+var _ = Suite(&TestMysqlSuite{})
+
+// Tests the inspection of MySQL errors
+func (suite *TestMysqlSuite) TestMySQLErrorCode(c *C) {
+	duplicateErr := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"}
+
+	testCases := []*struct {
+		err               error
+		expectedCode      uint16
+		expectedFound     bool
+		expectedDuplicate bool
+	}{
+		{duplicateErr, 1062, true, true},
+		{NewDatabaseError(duplicateErr), 1062, true, true},
+		{fmt.Errorf("Insert has error: %w", duplicateErr), 1062, true, true},
+		{NewDatabaseError(utils.BuildErrorWithCaller(&mysql.MySQLError{Number: 1213})), 1213, true, false},
+		{errors.New("Duplicate entry"), 0, false, false},
+		{nil, 0, false, false},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		code, found := MySQLErrorCode(testCase.err)
+		c.Assert(code, Equals, testCase.expectedCode, comment)
+		c.Assert(found, Equals, testCase.expectedFound, comment)
+		c.Assert(IsDuplicateKeyError(testCase.err), Equals, testCase.expectedDuplicate, comment)
+	}
+}
@@ -1,8 +1,11 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	or "github.com/Cepave/open-falcon-backend/common/runtime"
 	"github.com/Cepave/open-falcon-backend/common/utils"
@@ -11,18 +14,119 @@ import (
 type TxFinale byte
 
 const (
-	TxCommit   TxFinale = 1
+	TxCommit TxFinale = 1
+	// Rollbacks the transaction without error, e.g. nothing needs to be written
 	TxRollback TxFinale = 2
+	// Rollbacks the transaction because of failure, the error is raised as panic
+	//
+	// With TxCallbackE, the returned error is raised(or returned by InTxE()), ErrTxAborted is used if the error is nil.
+	TxAbort TxFinale = 3
 )
 
+// The error raised by TxAbort without attached error
+var ErrTxAborted = errors.New("Transaction is aborted")
+
 // Configuration of database
+//
+// The zero value of MaxIdle, MaxOpen, or ConnMaxLifetime means the default setting of "database/sql".
 type DbConfig struct {
 	Dsn     string
 	MaxIdle int
+	// The maximum number of open connections
+	MaxOpen int
+	// The maximum amount of time a connection may be reused
+	ConnMaxLifetime time.Duration
+	// The name of driver, "mysql" is used if this value is empty
+	//
+	// ToDbController() needs it to open the databases other than MySQL, e.g. "sqlite3" in tests.
+	Driver string
+	// The query to validate connections instead of ping, e.g. "SELECT 1" for ProxySQL
+	ValidationQuery string
 }
 
+// The password in DSN of MySQL is redacted
 func (config *DbConfig) String() string {
-	return fmt.Sprintf("DSN: [%s]. Max Idle: [%d]", config.Dsn, config.MaxIdle)
+	dsn := config.Dsn
+	if config.Driver == "" || config.Driver == "mysql" {
+		dsn = redactMySQLDsn(dsn)
+	}
+
+	return fmt.Sprintf(
+		"DSN: [%s]. Max Idle: [%d]. Max Open: [%d]. Conn Max Lifetime: [%v]",
+		dsn, config.MaxIdle, config.MaxOpen, config.ConnMaxLifetime,
+	)
+}
+
+// Opens the database and builds controller with settings of connection pool
+func (config *DbConfig) ToDbController() (*DbController, error) {
+	driverName := config.Driver
+	if driverName == "" {
+		driverName = "mysql"
+	}
+
+	connector, err := openConnector(driverName, config.Dsn)
+	if err != nil {
+		return nil, fmt.Errorf("Open database has error: %v", err)
+	}
+
+	dbController := NewDbControllerWithConnector(connector)
+	config.applyPoolSettings(dbController.dbObject)
+	dbController.validationQuery = config.ValidationQuery
+
+	return dbController, nil
+}
+
+// Opens the database by configuration and pings it
+//
+// The error is returned if the DSN is invalid or the database is unreachable,
+// the opened connections are released in that case.
+func OpenDbController(config *DbConfig) (*DbController, error) {
+	dbController, err := config.ToDbController()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dbController.Ping(); err != nil {
+		dbController.Release()
+		return nil, fmt.Errorf("Ping database has error: %v. %s", err, config)
+	}
+
+	return dbController, nil
+}
+
+// Copies the configuration with different sizes of connection pool
+//
+// Other settings(DSN, driver, ...) are kept, the original configuration is not modified.
+func (config *DbConfig) CloneWith(maxIdle, maxOpen int) *DbConfig {
+	clonedConfig := *config
+	clonedConfig.MaxIdle = maxIdle
+	clonedConfig.MaxOpen = maxOpen
+
+	return &clonedConfig
+}
+
+// Opens a separated controller with different sizes of connection pool on the same database
+//
+// For example, a background job could use fewer connections than the ones of web requests:
+//
+//	jobCtrl, err := OpenDbControllerWith(webConfig, 1, 2)
+//
+// The two controllers don't share connections, the total number of connections to the database
+// would be the sum of the two pools, so both of the controllers should be released respectively.
+func OpenDbControllerWith(config *DbConfig, maxIdle, maxOpen int) (*DbController, error) {
+	return OpenDbController(config.CloneWith(maxIdle, maxOpen))
+}
+
+func (config *DbConfig) applyPoolSettings(dbObject *sql.DB) {
+	if config.MaxIdle != 0 {
+		dbObject.SetMaxIdleConns(config.MaxIdle)
+	}
+	if config.MaxOpen != 0 {
+		dbObject.SetMaxOpenConns(config.MaxOpen)
+	}
+	if config.ConnMaxLifetime != 0 {
+		dbObject.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
 }
 
 // The main functions of this file is to gives IoC(Inverse of Control) of database(RDB) objects.
@@ -34,9 +138,44 @@ func (config *DbConfig) String() string {
 // Main controller of database
 type DbController struct {
 	dbObject      *sql.DB
-	panicHandlers []utils.PanicHandler
+	panicHandlers []PanicFilter
+
+	slowQueryThreshold time.Duration
+	slowQueryLogger    SlowQueryLogger
+
+	queryObserver QueryObserver
+	// See SetObserverSampleRate()
+	observerSampleRate int
+	observerCounter    *uint64
+	// The labels given to observer, see WithLabels()
+	labels map[string]string
+
+	defaultQueryTimeout time.Duration
+
+	reconnectOnError bool
+	validationQuery  string
+
+	stmtCache *stmtCache
+	// Shared by copies of controller, see CachedQueryForRows()
+	queryCache *queryCache
+
+	dryRun       bool
+	dryRunLogger DryRunLogger
+
+	// See SetTruncateTablesEnabled()
+	truncateTablesEnabled bool
+
+	// Only viable if the controller is built with connector
+	connector *hookedConnector
+
+	// Shared by copies of controller, which tracks the in-flight operations for Shutdown()
+	lifecycle *dbLifecycle
 }
 
+// The handler of panic, which returns false to let the panic be re-paniced
+// if none of other handlers has handled it
+type PanicFilter func(panicValue interface{}) (handled bool)
+
 // The interface of DB callback for sql package
 type DbCallback interface {
 	OnDb(db *sql.DB)
@@ -61,6 +200,38 @@ func (callbackFunc RowsCallbackFunc) NextRow(rows *sql.Rows) IterateControl {
 	return callbackFunc(rows)
 }
 
+// The interface of rows callback with zero-based index of row
+type IndexedRowsCallback interface {
+	NextRowAt(index uint, row *sql.Rows) IterateControl
+}
+
+// The function object delegates the IndexedRowsCallback interface
+type IndexedRowsCallbackFunc func(uint, *sql.Rows) IterateControl
+
+func (callbackFunc IndexedRowsCallbackFunc) NextRowAt(index uint, rows *sql.Rows) IterateControl {
+	return callbackFunc(index, rows)
+}
+
+// Wraps the callback to process at most n rows, the iteration is stopped after the n-th row
+//
+// The stop of inner callback is respected as well. The returned callback counts the rows,
+// so it should be built for every query.
+func LimitRows(n uint, inner RowsCallback) RowsCallback {
+	var processed uint
+	return RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+		if processed >= n {
+			return IterateStop
+		}
+
+		processed++
+		if inner.NextRow(rows) == IterateStop || processed >= n {
+			return IterateStop
+		}
+
+		return IterateContinue
+	})
+}
+
 // The interface of row callback for sql package
 type RowCallback interface {
 	ResultRow(row *sql.Row)
@@ -85,6 +256,37 @@ func (callbackFunc TxCallbackFunc) InTx(tx *sql.Tx) TxFinale {
 	return callbackFunc(tx)
 }
 
+// The function object delegates the TxCallback interface with error returned
+//
+// If the function returns non-nil error, the transaction is rollbacked regardless of the TxFinale,
+// the TxAbort should be returned with the error to make the intent clear:
+//
+//	return TxAbort, ErrQuotaExceeded
+//
+// With InTxE(), the error is returned as it is; other methods(e.g. InTx()) raise the error as a panic.
+type TxCallbackE func(*sql.Tx) (TxFinale, error)
+
+func (callbackFunc TxCallbackE) InTx(tx *sql.Tx) TxFinale {
+	finale, err := callbackFunc(tx)
+	if err != nil {
+		panic(&txCallbackError{err})
+	}
+
+	return finale
+}
+
+// The error returned by TxCallbackE, which is raised as panic to rollback the transaction
+type txCallbackError struct {
+	err error
+}
+
+func (e *txCallbackError) Error() string {
+	return e.err.Error()
+}
+func (e *txCallbackError) Unwrap() error {
+	return e.err
+}
+
 // BuildTxForSqls builds function for exeuction of multiple SQLs
 func BuildTxForSqls(queries ...string) TxCallback {
 	return TxCallbackFunc(func(tx *sql.Tx) TxFinale {
@@ -182,8 +384,15 @@ func (txExt *TxExt) Commit() {
 
 // Commit with panic instead of returned error
 func (txExt *TxExt) Exec(query string, args ...interface{}) sql.Result {
+	if dbController, ok := dryRunControllerOf((*sql.Tx)(txExt)); ok {
+		dbController.logDryRun(query, args)
+		return dryRunResult{}
+	}
+
 	result, err := ((*sql.Tx)(txExt)).Exec(query, args...)
-	PanicIfError(utils.BuildErrorWithCaller(err))
+	if err != nil {
+		PanicIfError(newSqlError("exec", query, args, err, or.GetCallerInfo()))
+	}
 
 	return result
 }
@@ -196,14 +405,113 @@ func (txExt *TxExt) Prepare(query string) *sql.Stmt {
 	return stmt
 }
 
+// Prepares the query once and executes it for every set of arguments, with panic instead of returned error
+//
+// The statement is closed even if one of the executions has panic.
+func (txExt *TxExt) ExecMany(query string, argsList [][]interface{}) []sql.Result {
+	if _, ok := dryRunControllerOf((*sql.Tx)(txExt)); ok {
+		results := make([]sql.Result, 0, len(argsList))
+		for _, args := range argsList {
+			results = append(results, txExt.Exec(query, args...))
+		}
+
+		return results
+	}
+
+	stmt := txExt.Prepare(query)
+	defer stmt.Close()
+
+	stmtExt := ToStmtExt(stmt)
+	results := make([]sql.Result, 0, len(argsList))
+	for _, args := range argsList {
+		results = append(results, stmtExt.Exec(args...))
+	}
+
+	return results
+}
+
 // Query with panic instead of returned error
 func (txExt *TxExt) Query(query string, args ...interface{}) *sql.Rows {
-	rows, err := ((*sql.Tx)(txExt)).Query(query)
-	PanicIfError(utils.BuildErrorWithCaller(err))
+	rows, err := ((*sql.Tx)(txExt)).Query(query, args...)
+	if err != nil {
+		PanicIfError(newSqlError("query", query, args, err, or.GetCallerInfo()))
+	}
 
 	return rows
 }
 
+// Query for rows in the transaction, which mirrors DbController.QueryForRows()
+//
+// The rows are closed after the iteration is finished.
+func (txExt *TxExt) QueryForRows(rowsCallback RowsCallback, query string, args ...interface{}) (numberOfRows uint) {
+	rows, err := ((*sql.Tx)(txExt)).Query(query, args...)
+	if err != nil {
+		PanicIfError(newSqlError("query", query, args, err, or.GetCallerInfo()))
+	}
+	defer rows.Close()
+	defer trackColumnIndex(rows)()
+
+	for rows.Next() {
+		numberOfRows++
+
+		if rowsCallback.NextRow(rows) == IterateStop {
+			break
+		}
+	}
+
+	PanicIfError(utils.BuildErrorWithCaller(rows.Err()))
+
+	return
+}
+
+// Query for a row in the transaction, which mirrors DbController.QueryForRow()
+func (txExt *TxExt) QueryForRow(rowCallback RowCallback, query string, args ...interface{}) {
+	row := ((*sql.Tx)(txExt)).QueryRow(query, args...)
+	if err := row.Err(); err != nil {
+		PanicIfError(newSqlError("query", query, args, err, or.GetCallerInfo()))
+	}
+
+	rowCallback.ResultRow(row)
+}
+
+// Ends the transaction by the error, which should be called by defer
+//
+// The transaction is committed if the error is nil, the error of committing is set to the error holder.
+// Otherwise, the transaction is rollbacked and the error is kept(with the error of rollback if there is any).
+//
+// If there is a raised panic, the transaction is rollbacked and the panic is raised again.
+//
+// If the transaction is begun in dry-run mode, it is rollbacked instead of being committed.
+func (txExt *TxExt) Finish(errHolder *error) {
+	tx := (*sql.Tx)(txExt)
+	dbController, dryRun := dryRunControllerOf(tx)
+	if dryRun {
+		defer dryRunTxs.Delete(tx)
+	}
+
+	if p := recover(); p != nil {
+		tx.Rollback()
+		panic(p)
+	}
+
+	if errHolder == nil || *errHolder == nil {
+		if dryRun {
+			dbController.logDryRun("COMMIT", nil)
+			tx.Rollback()
+			return
+		}
+
+		if err := tx.Commit(); err != nil && errHolder != nil {
+			*errHolder = utils.BuildErrorWithCaller(err)
+		}
+		return
+	}
+
+	if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		*errHolder = fmt.Errorf("Rollback has error: %v. Cause Error: %w", err, *errHolder)
+	}
+}
+
 // Rollback with panic instead of returned error
 func (txExt *TxExt) Rollback() {
 	err := ((*sql.Tx)(txExt)).Rollback()
@@ -257,15 +565,36 @@ func NewDbController(newDbObject *sql.DB) *DbController {
 
 	return &DbController{
 		dbObject:      newDbObject,
-		panicHandlers: make([]utils.PanicHandler, 0),
+		panicHandlers: make([]PanicFilter, 0),
+		lifecycle:     &dbLifecycle{},
+		queryCache:    newQueryCache(),
 	}
 }
 
 // Registers a handler while a panic is raised
 //
-// This object may register multiple handlers for panic
+// This object may register multiple handlers for panic.
+// The panic is considered as handled by this handler, see RegisterPanicFilter for selective handling.
 func (dbController *DbController) RegisterPanicHandler(panicHandler utils.PanicHandler) {
-	dbController.panicHandlers = append(dbController.panicHandlers, panicHandler)
+	dbController.RegisterPanicFilter(func(panicValue interface{}) bool {
+		panicHandler(panicValue)
+		return true
+	})
+}
+
+// Registers a filter while a panic is raised
+//
+// All of the registered handlers and filters are called in order,
+// the panic is re-paniced if none of them has handled it(every filter returns false).
+//
+// For example, to log the panic and re-raise it:
+//
+//	dbController.RegisterPanicFilter(func(panicValue interface{}) bool {
+//		log.Printf("Database has error: %v", panicValue)
+//		return false
+//	})
+func (dbController *DbController) RegisterPanicFilter(panicFilter PanicFilter) {
+	dbController.panicHandlers = append(dbController.panicHandlers, panicFilter)
 }
 
 // Operate on database
@@ -274,17 +603,60 @@ func (dbController *DbController) OperateOnDb(dbCallback DbCallback) {
 	defer dbController.handlePanic()
 	defer utils.DeferCatchPanicWithCaller()()
 
+	dbController.beginOperation()
+	defer dbController.endOperation()
+
 	dbCallback.OnDb(dbController.dbObject)
 }
 
+// Operates on a single connection checked out from the pool
+//
+// This is useful for session-scoped operations, e.g. "LAST_INSERT_ID()" or temporary tables of MySQL.
+//
+// The connection is always returned to the pool, then the panic raised by the function
+// would be captured by registered PanicHandlers.
+func (dbController *DbController) OnConn(ctx context.Context, fn func(*sql.Conn)) {
+	var dbFunc DbCallbackFunc = func(db *sql.DB) {
+		conn, err := db.Conn(ctx)
+		PanicIfError(utils.BuildErrorWithCaller(err))
+		defer conn.Close()
+
+		fn(conn)
+	}
+
+	dbController.OperateOnDb(dbFunc)
+}
+
 // Executes the query string or panic
 func (dbController *DbController) Exec(query string, args ...interface{}) sql.Result {
-	callerInfo := or.GetCallerInfo()
+	return dbController.execContext(context.Background(), or.GetCallerInfo(), query, args...)
+}
+
+// Executes the query string with context or panic
+//
+// If the context is cancelled or timed out, the raised panic would be captured by registered PanicHandlers.
+func (dbController *DbController) ExecContext(ctx context.Context, query string, args ...interface{}) sql.Result {
+	return dbController.execContext(ctx, or.GetCallerInfo(), query, args...)
+}
+
+func (dbController *DbController) execContext(
+	ctx context.Context, callerInfo *or.CallerInfo,
+	query string, args ...interface{},
+) sql.Result {
+	if dbController.dryRun {
+		dbController.logDryRun(query, args)
+		return dryRunResult{}
+	}
 
 	var finalResult sql.Result
 	var dbFunc DbCallbackFunc = func(db *sql.DB) {
-		r, err := db.Exec(query, args...)
-		PanicIfError(utils.BuildErrorWithCallerInfo(err, callerInfo))
+		ctx, cancel := dbController.withDefaultTimeout(ctx)
+		defer cancel()
+
+		r, err := dbController.execOnDb(ctx, db, query, args)
+		if err != nil {
+			PanicIfError(newSqlError("exec", query, args, err, callerInfo))
+		}
 
 		finalResult = r
 	}
@@ -299,28 +671,70 @@ func (dbController *DbController) QueryForRows(
 	sqlQuery string, args ...interface{},
 ) (numberOfRows uint) {
 	defer utils.DeferCatchPanicWithCaller()()
+	return dbController.queryForRows(context.Background(), nil, rowsCallback, sqlQuery, args, true)
+}
 
+// Query for rows with context and get called of rows with Next()
+//
+// The iteration would be stopped if the context is done, and the error of context would be raised as a panic.
+//
+// Unlike QueryForRows(), the error of iteration(rows.Err()) is raised as a panic as well.
+func (dbController *DbController) QueryForRowsContext(
+	ctx context.Context,
+	rowsCallback RowsCallback,
+	sqlQuery string, args ...interface{},
+) (numberOfRows uint) {
+	defer utils.DeferCatchPanicWithCaller()()
+	return dbController.queryForRowsContext(ctx, nil, rowsCallback, sqlQuery, args)
+}
+
+// The "onColumns" is called with the names of columns before iterating rows, if it is not nil
+func (dbController *DbController) queryForRowsContext(
+	ctx context.Context,
+	onColumns func(columns []string), rowsCallback RowsCallback,
+	sqlQuery string, args []interface{},
+) (numberOfRows uint) {
+	return dbController.queryForRows(ctx, onColumns, rowsCallback, sqlQuery, args, false)
+}
+
+// The "ignoreRowsErr" keeps the behavior of QueryForRows() before the context is supported,
+// which doesn't raise the error of iteration(only the error of context, e.g. the default timeout, is raised).
+func (dbController *DbController) queryForRows(
+	ctx context.Context,
+	onColumns func(columns []string), rowsCallback RowsCallback,
+	sqlQuery string, args []interface{},
+	ignoreRowsErr bool,
+) (numberOfRows uint) {
 	var dbFunc DbCallbackFunc = func(db *sql.DB) {
-		rows, err := db.Query(
-			sqlQuery, args...,
-		)
+		ctx, cancel := dbController.withDefaultTimeout(ctx)
+		defer cancel()
+
+		rows, err := dbController.queryOnDb(ctx, db, sqlQuery, args)
 
 		if err != nil {
-			err := fmt.Errorf(
-				"Query SQL with exception: %v. SQL: \"%s\" Params: %#v",
-				err, sqlQuery, args,
-			)
-			PanicIfError(utils.BuildErrorWithCaller(err))
+			PanicIfError(newSqlError("query", sqlQuery, args, err, or.GetCallerInfo()))
 		}
 
 		defer rows.Close()
+		defer trackColumnIndex(rows)()
+		if onColumns != nil {
+			onColumns(ToRowsExt(rows).Columns())
+		}
+
 		for rows.Next() {
+			PanicIfError(utils.BuildErrorWithCaller(ctx.Err()))
 			numberOfRows++
 
 			if rowsCallback.NextRow(rows) == IterateStop {
 				break
 			}
 		}
+
+		if ignoreRowsErr {
+			PanicIfError(utils.BuildErrorWithCaller(ctx.Err()))
+			return
+		}
+		PanicIfError(utils.BuildErrorWithCaller(rows.Err()))
 	}
 
 	dbController.OperateOnDb(dbFunc)
@@ -328,17 +742,47 @@ func (dbController *DbController) QueryForRows(
 	return
 }
 
+// Query for rows and get called of rows with zero-based index of row
+func (dbController *DbController) QueryForRowsIndexed(
+	rowsCallback IndexedRowsCallback,
+	sqlQuery string, args ...interface{},
+) (numberOfRows uint) {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	var index uint = 0
+	return dbController.QueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			currentIndex := index
+			index++
+
+			return rowsCallback.NextRowAt(currentIndex, rows)
+		}),
+		sqlQuery, args...,
+	)
+}
+
 // Query for a row and get called if the query is not failed
 func (dbController *DbController) QueryForRow(
 	rowCallback RowCallback,
 	sqlQuery string, args ...interface{},
 ) {
 	defer utils.DeferCatchPanicWithCaller()()
+	dbController.QueryForRowContext(context.Background(), rowCallback, sqlQuery, args...)
+}
+
+// Query for a row with context and get called if the query is not failed
+func (dbController *DbController) QueryForRowContext(
+	ctx context.Context,
+	rowCallback RowCallback,
+	sqlQuery string, args ...interface{},
+) {
+	defer utils.DeferCatchPanicWithCaller()()
 
 	var dbFunc DbCallbackFunc = func(db *sql.DB) {
-		row := db.QueryRow(
-			sqlQuery, args...,
-		)
+		ctx, cancel := dbController.withDefaultTimeout(ctx)
+		defer cancel()
+
+		row := dbController.queryRowOnDb(ctx, db, sqlQuery, args)
 
 		rowCallback.ResultRow(row)
 	}
@@ -352,13 +796,103 @@ func (dbController *DbController) QueryForRow(
 // rollback it otherwise.
 func (dbController *DbController) InTx(txCallback TxCallback) {
 	defer utils.DeferCatchPanicWithCaller()()
+	dbController.inTx(context.Background(), nil, or.GetCallerInfo(), txCallback)
+}
+
+// Begins a transaction, which should be committed or rollbacked by the caller
+//
+// This method is for flows which interleave the logic of application and the operations of database
+// across functions, the Finish() could be deferred to end the transaction with the returned error:
+//
+//	func updateAgent(dbCtrl *DbController) (err error) {
+//		txExt := dbCtrl.Begin()
+//		defer txExt.Finish(&err)
+//
+//		txExt.Exec("UPDATE nqm_agent SET ag_status = 1 WHERE ag_id = ?", 1)
+//		return checkAgent()
+//	}
+//
+// The nil value is returned if the panic is handled by registered PanicHandlers.
+func (dbController *DbController) Begin() *TxExt {
+	defer utils.DeferCatchPanicWithCaller()()
 
+	var tx *sql.Tx
 	var dbFunc DbCallbackFunc = func(db *sql.DB) {
-		callerInfo := or.GetCallerInfo()
+		var err error
+		tx, err = db.Begin()
+		PanicIfError(utils.BuildErrorWithCaller(err))
+
+		if dbController.dryRun {
+			dryRunTxs.Store(tx, dbController)
+		}
+	}
+
+	dbController.OperateOnDb(dbFunc)
+
+	return ToTxExt(tx)
+}
+
+// Executes in transaction with options(isolation level, read-only...).
+//
+// The nil value of options would use default options of driver.
+func (dbController *DbController) InTxWithOpts(opts *sql.TxOptions, txCallback TxCallback) {
+	defer utils.DeferCatchPanicWithCaller()()
+	dbController.inTx(context.Background(), opts, or.GetCallerInfo(), txCallback)
+}
 
-		tx, err := db.Begin()
+// Executes the reads in a read-only transaction of "REPEATABLE READ", so the reads see the same snapshot of data
+//
+// This method is useful for reports with multiple SELECTs, the commits of writers between the reads are not visible.
+// The transaction is always rollbacked since nothing should be written.
+func (dbController *DbController) InTxSnapshot(fn func(*TxExt)) {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	dbController.InTxWithOpts(
+		&sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true},
+		TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+			fn(ToTxExt(tx))
+			return TxRollback
+		}),
+	)
+}
+
+// Executes in transaction with context.
+//
+// If the context is cancelled while the callback is running,
+// the transaction would be rollbacked and the error of context would be raised as a panic.
+//
+// If the callback gives TxRollback, the finale is respected even if the transaction has been
+// rollbacked by cancellation of the context.
+func (dbController *DbController) InTxContext(ctx context.Context, txCallback TxCallback) {
+	defer utils.DeferCatchPanicWithCaller()()
+	dbController.inTx(ctx, nil, or.GetCallerInfo(), txCallback)
+}
+
+func (dbController *DbController) inTx(
+	ctx context.Context, opts *sql.TxOptions,
+	callerInfo *or.CallerInfo, txCallback TxCallback,
+) {
+	dbController.OperateOnDb(dbController.buildTxFunc(ctx, opts, callerInfo, txCallback))
+}
+
+func (dbController *DbController) buildTxFunc(
+	ctx context.Context, opts *sql.TxOptions,
+	callerInfo *or.CallerInfo, txCallback TxCallback,
+) DbCallbackFunc {
+	return func(db *sql.DB) {
+		finale := TxRollback
+		if dbController.queryObserver != nil {
+			defer dbController.observeTx(time.Now(), &finale)
+		}
+
+		tx, err := db.BeginTx(ctx, opts)
 		PanicIfError(utils.BuildErrorWithCallerInfo(err, callerInfo))
 
+		if dbController.dryRun {
+			dryRunTxs.Store(tx, dbController)
+			defer dryRunTxs.Delete(tx)
+		}
+
 		/**
 		 * Rollback the transaction when panic is raised
 		 */
@@ -390,36 +924,164 @@ func (dbController *DbController) InTx(txCallback TxCallback) {
 		txExt := ToTxExt(tx)
 		switch txCallback.InTx(tx) {
 		case TxCommit:
+			if dbController.dryRun {
+				dbController.logDryRun("COMMIT", nil)
+				txExt.Rollback()
+				return
+			}
+
 			txExt.Commit()
+			finale = TxCommit
 		case TxRollback:
+			/**
+			 * The transaction has been rollbacked by cancellation of context
+			 */
+			if ctx.Err() != nil {
+				if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+					PanicIfError(utils.BuildErrorWithCallerInfo(err, callerInfo))
+				}
+				return
+			}
+			// :~)
+
 			txExt.Rollback()
+		case TxAbort:
+			PanicIfError(utils.BuildErrorWithCallerInfo(ErrTxAborted, callerInfo))
 		}
 	}
-
-	dbController.OperateOnDb(dbFunc)
 }
 
 // Executes the complex statement in transaction
 func (dbController *DbController) InTxForIf(ifCallbacks ExecuteIfByTx) {
 	defer utils.DeferCatchPanicWithCaller()()
+	dbController.inTx(context.Background(), nil, or.GetCallerInfo(), buildTxForIf(context.Background(), ifCallbacks))
+}
 
-	var txFunc TxCallbackFunc = func(tx *sql.Tx) TxFinale {
-		if ifCallbacks.BootCallback(tx) {
-			ifCallbacks.IfTrue(tx)
+// Executes the complex statement in transaction with context
+//
+// The transaction is began with the context, so the *sql.Tx given to callbacks is bound to the context.
+// If the context is done after the boot callback, IfTrue() is not called.
+//
+// Once the context is cancelled, the transaction would be rollbacked and
+// the error of context would be raised as a panic(same as InTxContext()).
+func (dbController *DbController) InTxForIfContext(ctx context.Context, ifCallbacks ExecuteIfByTx) {
+	defer utils.DeferCatchPanicWithCaller()()
+	dbController.inTx(ctx, nil, or.GetCallerInfo(), buildTxForIf(ctx, ifCallbacks))
+}
+
+func buildTxForIf(ctx context.Context, ifCallbacks ExecuteIfByTx) TxCallbackFunc {
+	return func(tx *sql.Tx) TxFinale {
+		if !ifCallbacks.BootCallback(tx) {
+			return TxCommit
 		}
 
+		PanicIfError(utils.BuildErrorWithCaller(ctx.Err()))
+		ifCallbacks.IfTrue(tx)
+
 		return TxCommit
 	}
-
-	dbController.InTx(txFunc)
 }
 
 // Executes in transaction
 func (dbController *DbController) ExecQueriesInTx(queries ...string) {
 	defer utils.DeferCatchPanicWithCaller()()
+
+	if dbController.dryRun {
+		for _, query := range queries {
+			dbController.logDryRun(query, nil)
+		}
+		return
+	}
+
 	dbController.InTx(BuildTxForSqls(queries...))
 }
 
+// Executes in transaction and gets the number of affected rows for every query
+//
+// The transaction gets rollback and the panic is raised if any of the queries has error.
+func (dbController *DbController) ExecQueriesInTxCounting(queries ...string) []int64 {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	numbersOfAffected := make([]int64, 0, len(queries))
+	dbController.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		txExt := ToTxExt(tx)
+		for _, query := range queries {
+			numbersOfAffected = append(
+				numbersOfAffected,
+				ToResultExt(txExt.Exec(query)).RowsAffected(),
+			)
+		}
+
+		return TxCommit
+	}))
+
+	return numbersOfAffected
+}
+
+// Sets the timeout for every query(Exec, QueryForRows, QueryForRow, and theirs variants with context)
+//
+// If the context given by caller has a deadline, the earlier one is used.
+//
+// The zero value disables the timeout.
+func (dbController *DbController) SetDefaultQueryTimeout(d time.Duration) {
+	dbController.defaultQueryTimeout = d
+}
+
+func (dbController *DbController) withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if dbController.defaultQueryTimeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, dbController.defaultQueryTimeout)
+}
+
+// Pings the database, the error is returned instead of panic
+func (dbController *DbController) Ping() error {
+	return dbController.PingContext(context.Background())
+}
+
+// Pings the database with context, the error is returned instead of panic
+//
+// If the validation query is set, the query is executed instead of ping.
+func (dbController *DbController) PingContext(ctx context.Context) error {
+	if dbController.dbObject == nil {
+		return fmt.Errorf("The controller is not initialized")
+	}
+
+	if dbController.validationQuery == "" {
+		return dbController.dbObject.PingContext(ctx)
+	}
+
+	rows, err := dbController.dbObject.QueryContext(ctx, dbController.validationQuery)
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		if closeErr := rows.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Validation query has error: %w. SQL: \"%s\"", err, dbController.validationQuery)
+	}
+
+	return nil
+}
+
+// Sets the query to validate connections instead of ping, e.g. "SELECT 1" for ProxySQL
+//
+// The query is used by Ping(), OpenDbController(), and the retrying of SetReconnectOnError().
+// The empty value means the ping of driver is used.
+func (dbController *DbController) SetValidationQuery(query string) {
+	dbController.validationQuery = query
+}
+
+// Gets the statistics of database, e.g. number of open or idle connections
+func (dbController *DbController) Stats() sql.DBStats {
+	dbController.needInitializedOrPanic()
+	return dbController.dbObject.Stats()
+}
+
 // Releases the database object under this object
 //
 // As of service application(web, daemon...), this method is rarely get called
@@ -427,6 +1089,10 @@ func (dbController *DbController) Release() {
 	dbController.needInitializedOrPanic()
 	defer dbController.handlePanic()
 
+	if dbController.stmtCache != nil {
+		dbController.stmtCache.clear()
+	}
+
 	err := dbController.dbObject.Close()
 
 	if err != nil {
@@ -438,6 +1104,87 @@ func (dbController *DbController) Release() {
 	dbController.dbObject = nil
 }
 
+func (dbController *DbController) execOnDb(
+	ctx context.Context, db *sql.DB,
+	query string, args []interface{},
+) (sql.Result, error) {
+	startTime := time.Now()
+
+	var result sql.Result
+	err := dbController.retryOnConnectionError(func() (err error) {
+		if dbController.stmtCache != nil {
+			return dbController.withCachedStmt(ctx, db, query, func(stmt *sql.Stmt) (stmtErr error) {
+				result, stmtErr = stmt.ExecContext(ctx, args...)
+				return
+			})
+		}
+
+		result, err = db.ExecContext(ctx, query, args...)
+		return
+	})
+
+	dbController.checkSlowQuery(query, args, time.Since(startTime))
+	dbController.observeQuery("exec", query, startTime, err)
+
+	return result, err
+}
+func (dbController *DbController) queryOnDb(
+	ctx context.Context, db *sql.DB,
+	query string, args []interface{},
+) (*sql.Rows, error) {
+	startTime := time.Now()
+
+	var rows *sql.Rows
+	err := dbController.retryOnConnectionError(func() (err error) {
+		if dbController.stmtCache != nil {
+			return dbController.withCachedStmt(ctx, db, query, func(stmt *sql.Stmt) (stmtErr error) {
+				rows, stmtErr = stmt.QueryContext(ctx, args...)
+				return
+			})
+		}
+
+		rows, err = db.QueryContext(ctx, query, args...)
+		return
+	})
+
+	dbController.checkSlowQuery(query, args, time.Since(startTime))
+	dbController.observeQuery("query", query, startTime, err)
+
+	return rows, err
+}
+func (dbController *DbController) queryRowOnDb(
+	ctx context.Context, db *sql.DB,
+	query string, args []interface{},
+) *sql.Row {
+	startTime := time.Now()
+
+	var row *sql.Row
+	err := dbController.retryOnConnectionError(func() error {
+		if dbController.stmtCache != nil {
+			row = nil
+			err := dbController.withCachedStmt(ctx, db, query, func(stmt *sql.Stmt) error {
+				row = stmt.QueryRowContext(ctx, args...)
+				return row.Err()
+			})
+			if row == nil {
+				return err
+			}
+		} else {
+			row = db.QueryRowContext(ctx, query, args...)
+		}
+
+		return row.Err()
+	})
+	if row == nil {
+		PanicIfError(newSqlError("query", query, args, err, or.GetCallerInfo()))
+	}
+
+	dbController.checkSlowQuery(query, args, time.Since(startTime))
+	dbController.observeQuery("query", query, startTime, row.Err())
+
+	return row
+}
+
 func (dbController *DbController) needInitializedOrPanic() {
 	if dbController.dbObject != nil {
 		return
@@ -449,17 +1196,28 @@ func (dbController *DbController) needInitializedOrPanic() {
 	))
 }
 
+// Raises the error with caller info of the calling method, which could be captured by registered PanicHandlers
+//
+// This method is used by validation before the operation(e.g. OperateOnDb()) is performed.
+func (dbController *DbController) raiseToHandlers(err error) {
+	defer dbController.handlePanic()
+	PanicIfError(utils.BuildErrorWithCallerDepth(err, 1))
+}
+
 func (dbController *DbController) handlePanic() {
 	p := recover()
 	if p == nil {
 		return
 	}
 
-	if len(dbController.panicHandlers) == 0 {
-		panic(p)
+	handled := false
+	for _, handler := range dbController.panicHandlers {
+		if handler(p) {
+			handled = true
+		}
 	}
 
-	for _, handler := range dbController.panicHandlers {
-		handler(p)
+	if !handled {
+		panic(p)
 	}
 }
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// Inserts multiple rows by single statement:
+//
+//	INSERT INTO <table>(<col_1>, <col_2>) VALUES(?, ?), (?, ?), ...
+//
+// Every row must have the same number of values as columns, otherwise this method panics.
+func (dbController *DbController) BulkInsert(table string, columns []string, rows [][]interface{}) sql.Result {
+	defer utils.DeferCatchPanicWithCaller()()
+	return dbController.BulkInsertByChunk(table, columns, rows, 0)
+}
+
+// Inserts multiple rows, the rows are split into statements with at most "chunkSize" rows.
+//
+// This method could be used to prevent the size of statement from exceeding "max_allowed_packet" of MySQL.
+//
+// If there are multiple chunks, all of the statements are executed in one transaction.
+//
+// The non-positive value of chunkSize means there is only one statement.
+//
+// The invalid rows raise a panic which could be captured by registered PanicHandlers, the result is nil if the panic is handled.
+func (dbController *DbController) BulkInsertByChunk(
+	table string, columns []string, rows [][]interface{},
+	chunkSize int,
+) sql.Result {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	if err := validateBulkRows(table, columns, rows); err != nil {
+		dbController.raiseToHandlers(err)
+		return nil
+	}
+
+	if chunkSize <= 0 || chunkSize > len(rows) {
+		chunkSize = len(rows)
+	}
+
+	if chunkSize == len(rows) {
+		return dbController.Exec(buildBulkInsertSql(table, columns, len(rows)), flattenRows(rows)...)
+	}
+
+	finalResult := &bulkResult{}
+	dbController.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		txExt := ToTxExt(tx)
+
+		for start := 0; start < len(rows); start += chunkSize {
+			end := start + chunkSize
+			if end > len(rows) {
+				end = len(rows)
+			}
+
+			chunk := rows[start:end]
+			finalResult.add(
+				txExt.Exec(buildBulkInsertSql(table, columns, len(chunk)), flattenRows(chunk)...),
+			)
+		}
+
+		return TxCommit
+	}))
+
+	return finalResult
+}
+
+func validateBulkRows(table string, columns []string, rows [][]interface{}) error {
+	if len(columns) == 0 || len(rows) == 0 {
+		return fmt.Errorf("Need columns and rows for bulk insert. Table: [%s]", table)
+	}
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return fmt.Errorf(
+				"Number of values of row[%d] is %d, but number of columns is %d. Table: [%s]",
+				i, len(row), len(columns), table,
+			)
+		}
+	}
+
+	return nil
+}
+
+func buildBulkInsertSql(table string, columns []string, numberOfRows int) string {
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+
+	values := make([]string, numberOfRows)
+	for i := range values {
+		values[i] = placeholders
+	}
+
+	return fmt.Sprintf(
+		"INSERT INTO %s(%s) VALUES%s",
+		table, strings.Join(columns, ", "), strings.Join(values, ", "),
+	)
+}
+
+func flattenRows(rows [][]interface{}) []interface{} {
+	args := make([]interface{}, 0, len(rows)*len(rows[0]))
+	for _, row := range rows {
+		args = append(args, row...)
+	}
+
+	return args
+}
+
+// Accumulated result of multiple statements
+//
+// The LastInsertId() gives the value of last statement.
+type bulkResult struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r *bulkResult) add(result sql.Result) {
+	/**
+	 * Some of drivers don't support last inserted id
+	 */
+	if lastInsertId, err := result.LastInsertId(); err == nil {
+		r.lastInsertId = lastInsertId
+	}
+	// :~)
+
+	r.rowsAffected += ToResultExt(result).RowsAffected()
+}
+func (r *bulkResult) LastInsertId() (int64, error) {
+	return r.lastInsertId, nil
+}
+func (r *bulkResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
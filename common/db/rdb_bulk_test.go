@@ -0,0 +1,87 @@
+package db
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbBulkSuite struct{}
+
+var _ = Suite(&TestRdbBulkSuite{})
+
+// Tests the building of SQL for bulk insert
+func (suite *TestRdbBulkSuite) TestBuildBulkInsertSql(c *C) {
+	c.Assert(
+		buildBulkInsertSql("nqm_ping", []string{"np_id", "np_rtt"}, 3),
+		Equals, "INSERT INTO nqm_ping(np_id, np_rtt) VALUES(?, ?), (?, ?), (?, ?)",
+	)
+	c.Assert(
+		buildBulkInsertSql("nqm_ping", []string{"np_id"}, 1),
+		Equals, "INSERT INTO nqm_ping(np_id) VALUES(?)",
+	)
+}
+
+// Tests the bulk insert with(or without) chunks
+func (suite *TestRdbBulkSuite) TestBulkInsertByChunk(c *C) {
+	testCases := []*struct {
+		numberOfRows       int
+		chunkSize          int
+		expectedStatements int
+	}{
+		{5, 0, 1},
+		{5, 5, 1},
+		{5, 2, 3},
+		{6, 2, 3},
+		{6, 10, 1},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		fakeDb := &fakeDriverDb{}
+		testedCtrl := newFakeDbController(fakeDb)
+
+		rows := make([][]interface{}, testCase.numberOfRows)
+		for j := range rows {
+			rows[j] = []interface{}{j, "v"}
+		}
+
+		testedCtrl.BulkInsertByChunk("nqm_ping", []string{"np_id", "np_name"}, rows, testCase.chunkSize)
+
+		c.Assert(fakeDb.executed(), HasLen, testCase.expectedStatements, comment)
+		testedCtrl.Release()
+	}
+}
+
+// Tests the bulk insert into database
+func (suite *TestRdbBulkSuite) TestBulkInsert(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec("CREATE TABLE nqm_ping(np_id INT PRIMARY KEY, np_name VARCHAR(16))")
+
+	result := testedCtrl.BulkInsertByChunk(
+		"nqm_ping", []string{"np_id", "np_name"},
+		[][]interface{}{{1, "a"}, {2, "b"}, {3, "c"}},
+		2,
+	)
+	c.Assert(ToResultExt(result).RowsAffected(), Equals, int64(3))
+
+	c.Assert(
+		func() {
+			testedCtrl.BulkInsert("nqm_ping", []string{"np_id", "np_name"}, [][]interface{}{{4, "d"}, {5}})
+		},
+		PanicMatches, ".*Number of values of row\\[1\\].*",
+	)
+
+	/**
+	 * The invalid rows are raised to the handlers with caller of bulk insert
+	 */
+	var err error
+	capturingCtrl := *testedCtrl
+	capturingCtrl.panicHandlers = nil
+	capturingCtrl.RegisterPanicHandler(NewDbErrorCapture(&err))
+
+	c.Assert(capturingCtrl.BulkInsert("nqm_ping", []string{"np_id"}, nil), IsNil)
+	c.Assert(err, ErrorMatches, "(?s).*rdb_bulk.go.*Need columns and rows.*")
+	// :~)
+}
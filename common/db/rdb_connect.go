@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// The hook called once for every newly established connection
+//
+// For example, to set session variables of MySQL:
+//
+//	func(ctx context.Context, conn *sql.Conn) error {
+//		_, err := conn.ExecContext(ctx, "SET SESSION time_zone = '+00:00'")
+//		return err
+//	}
+//
+// If the hook returns an error, the connection is closed and the error is returned to the query.
+type ConnectHook func(ctx context.Context, conn *sql.Conn) error
+
+// Builds a controller on the connector, which supports SetConnectHook()
+func NewDbControllerWithConnector(connector driver.Connector) *DbController {
+	hookedConnector := &hookedConnector{connector: connector}
+
+	dbController := NewDbController(sql.OpenDB(hookedConnector))
+	dbController.connector = hookedConnector
+
+	return dbController
+}
+
+// Sets the hook for newly established connections
+//
+// Since the connections are established by driver, this method requires the controller
+// to wrap the DSN in a connector, which is built by DbConfig.ToDbController() or NewDbControllerWithConnector().
+// The panic is raised if the controller is built by NewDbController(*sql.DB).
+//
+// The hook is not applied to the connections which have been established, so it should be set before any query.
+func (dbController *DbController) SetConnectHook(hook ConnectHook) {
+	if dbController.connector == nil {
+		PanicIfError(utils.BuildErrorWithCaller(
+			fmt.Errorf("Connect hook needs the controller built with connector"),
+		))
+	}
+
+	dbController.connector.setHook(hook)
+}
+
+// Builds the connector for the DSN of driver
+func openConnector(driverName string, dsn string) (driver.Connector, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	if driverContext, ok := db.Driver().(driver.DriverContext); ok {
+		return driverContext.OpenConnector(dsn)
+	}
+
+	return &dsnConnector{dsn, db.Driver()}, nil
+}
+
+// The connector for driver without implementation of driver.DriverContext
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c *dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// The connector calls the hook for every new connection
+type hookedConnector struct {
+	connector driver.Connector
+
+	lock sync.RWMutex
+	hook ConnectHook
+}
+
+func (c *hookedConnector) setHook(hook ConnectHook) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.hook = hook
+}
+
+func (c *hookedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.RLock()
+	hook := c.hook
+	c.lock.RUnlock()
+
+	if hook == nil {
+		return conn, nil
+	}
+
+	if err := runConnectHook(ctx, conn, c.connector.Driver(), hook); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Connect hook has error: %v", err)
+	}
+
+	return conn, nil
+}
+func (c *hookedConnector) Driver() driver.Driver {
+	return c.connector.Driver()
+}
+
+// Calls the hook with *sql.Conn wrapping the connection of driver
+//
+// The wrapped connection is not closed after the hook is finished.
+func runConnectHook(ctx context.Context, conn driver.Conn, d driver.Driver, hook ConnectHook) error {
+	hookDb := sql.OpenDB(&pinnedConnector{&unclosableConn{conn}, d})
+	defer hookDb.Close()
+
+	sqlConn, err := hookDb.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer sqlConn.Close()
+
+	return hook(ctx, sqlConn)
+}
+
+// The connector always gives the same connection
+type pinnedConnector struct {
+	conn   driver.Conn
+	driver driver.Driver
+}
+
+func (c *pinnedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.conn, nil
+}
+func (c *pinnedConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// The connection ignores Close(), the optional interfaces are delegated to the wrapped one
+type unclosableConn struct {
+	driver.Conn
+}
+
+func (c *unclosableConn) Close() error {
+	return nil
+}
+func (c *unclosableConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+
+	return c.Conn.Begin()
+}
+func (c *unclosableConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := c.Conn.(driver.ExecerContext); ok {
+		return execer.ExecContext(ctx, query, args)
+	}
+
+	return nil, driver.ErrSkip
+}
+func (c *unclosableConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := c.Conn.(driver.QueryerContext); ok {
+		return queryer.QueryContext(ctx, query, args)
+	}
+
+	return nil, driver.ErrSkip
+}
+func (c *unclosableConn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+
+	return driver.ErrSkip
+}
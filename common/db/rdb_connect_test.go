@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbConnectSuite struct{}
+
+var _ = Suite(&TestRdbConnectSuite{})
+
+var (
+	fakeHookDb           = &fakeDriverDb{}
+	registerFakeHookOnce sync.Once
+)
+
+func buildFakeHookDbController(c *C) *DbController {
+	registerFakeHookOnce.Do(func() {
+		sql.Register("fake-hook", fakeHookDb.Driver())
+	})
+
+	testedCtrl, err := (&DbConfig{Driver: "fake-hook", MaxOpen: 1}).ToDbController()
+	c.Assert(err, IsNil)
+
+	return testedCtrl
+}
+
+// Tests the calling of hook for new connections
+func (suite *TestRdbConnectSuite) TestSetConnectHook(c *C) {
+	testedCtrl := buildFakeHookDbController(c)
+	defer testedCtrl.Release()
+
+	numberOfCalled := 0
+	testedCtrl.SetConnectHook(func(ctx context.Context, conn *sql.Conn) error {
+		numberOfCalled++
+		_, err := conn.ExecContext(ctx, "SET SESSION time_zone = '+00:00'")
+		return err
+	})
+
+	numberOfExecuted := len(fakeHookDb.executed())
+
+	testedCtrl.Exec("UPDATE hook_t1 SET a = 1")
+	testedCtrl.Exec("UPDATE hook_t1 SET a = 2")
+
+	c.Assert(numberOfCalled, Equals, 1)
+	c.Assert(fakeHookDb.executed()[numberOfExecuted:], DeepEquals, []string{
+		"SET SESSION time_zone = '+00:00'",
+		"UPDATE hook_t1 SET a = 1",
+		"UPDATE hook_t1 SET a = 2",
+	})
+}
+
+// Tests the failed query by error of hook
+func (suite *TestRdbConnectSuite) TestSetConnectHookWithError(c *C) {
+	testedCtrl := buildFakeHookDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.SetConnectHook(func(ctx context.Context, conn *sql.Conn) error {
+		return fmt.Errorf("Unknown time zone")
+	})
+
+	c.Assert(
+		func() { testedCtrl.Exec("UPDATE hook_t2 SET a = 1") },
+		PanicMatches, ".*Connect hook has error: Unknown time zone.*",
+	)
+}
+
+// Tests the panic for controller without connector
+func (suite *TestRdbConnectSuite) TestSetConnectHookWithoutConnector(c *C) {
+	testedCtrl := newFakeDbController(&fakeDriverDb{})
+	defer testedCtrl.Release()
+
+	c.Assert(
+		func() {
+			testedCtrl.SetConnectHook(func(ctx context.Context, conn *sql.Conn) error { return nil })
+		},
+		PanicMatches, ".*connector.*",
+	)
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"database/sql"
+	"sync"
+)
+
+// The logger for statements skipped by dry-run mode
+//
+// The arguments are redacted by ArgRedactor if it is set.
+type DryRunLogger func(sql string, args []interface{})
+
+// Enables or disables the dry-run mode
+//
+// While the mode is enabled:
+//
+//	Exec(and its variants) and ExecQueriesInTx(and its variants) - The statements are logged and skipped, the result has zero RowsAffected.
+//	InTx(and its variants) - The callback is performed but the transaction is always rollback, the skipped "COMMIT" is logged.
+//		The statements executed by TxExt.Exec() and TxExt.ExecMany() are logged and skipped too,
+//		since some of them(e.g. DDL of MySQL) would be committed implicitly.
+//		The statements executed by sql.Tx directly are not intercepted.
+//
+// The read queries(QueryForRows, QueryForRow, etc.) are executed normally.
+func (dbController *DbController) SetDryRun(enabled bool) {
+	dbController.dryRun = enabled
+}
+
+// Sets the logger of dry-run mode, the nil value would use Logger
+func (dbController *DbController) SetDryRunLogger(logger DryRunLogger) {
+	dbController.dryRunLogger = logger
+}
+
+func (dbController *DbController) logDryRun(sql string, args []interface{}) {
+	args = redactArgs(args)
+
+	if dbController.dryRunLogger != nil {
+		dbController.dryRunLogger(sql, args)
+		return
+	}
+
+	Logger.Printf("[Dry Run] SQL: \"%s\" Params: %#v", sql, args)
+}
+
+// The transactions begun by controller of dry-run mode, which are kept until they are finished
+//
+// Since TxExt is converted from sql.Tx, it has no reference to the controller.
+var dryRunTxs sync.Map
+
+// Gets the controller of dry-run mode which has begun the transaction
+func dryRunControllerOf(tx *sql.Tx) (*DbController, bool) {
+	dbController, ok := dryRunTxs.Load(tx)
+	if !ok {
+		return nil, false
+	}
+
+	return dbController.(*DbController), true
+}
+
+// The result of skipped statement, which has zero values
+type dryRunResult struct{}
+
+func (dryRunResult) LastInsertId() (int64, error) {
+	return 0, nil
+}
+func (dryRunResult) RowsAffected() (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"database/sql"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbDryRunSuite struct{}
+
+var _ = Suite(&TestRdbDryRunSuite{})
+
+// Tests the skipping of writes in dry-run mode
+func (suite *TestRdbDryRunSuite) TestDryRun(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.ExecQueriesInTx(
+		"CREATE TABLE car_dr01(c_id INT PRIMARY KEY, c_name VARCHAR(64))",
+		"INSERT INTO car_dr01 VALUES(1, 'c1')",
+	)
+
+	var loggedSqls []string
+	var loggedArgs [][]interface{}
+	testedCtrl.SetDryRunLogger(func(sql string, args []interface{}) {
+		loggedSqls = append(loggedSqls, sql)
+		loggedArgs = append(loggedArgs, args)
+	})
+	testedCtrl.SetDryRun(true)
+
+	result := testedCtrl.Exec("DELETE FROM car_dr01 WHERE c_id = ?", 1)
+	c.Assert(ToResultExt(result).RowsAffected(), Equals, int64(0))
+
+	testedCtrl.ExecQueriesInTx(
+		"INSERT INTO car_dr01 VALUES(2, 'c2')",
+		"UPDATE car_dr01 SET c_name = 'up'",
+	)
+	testedCtrl.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		ToTxExt(tx).Exec("INSERT INTO car_dr01 VALUES(3, 'c3')")
+		return TxCommit
+	}))
+
+	c.Assert(loggedSqls, DeepEquals, []string{
+		"DELETE FROM car_dr01 WHERE c_id = ?",
+		"INSERT INTO car_dr01 VALUES(2, 'c2')",
+		"UPDATE car_dr01 SET c_name = 'up'",
+		"INSERT INTO car_dr01 VALUES(3, 'c3')",
+		"COMMIT",
+	})
+	c.Assert(loggedArgs[0], DeepEquals, []interface{}{1})
+
+	/**
+	 * Asserts that reads are executed and no write has occurred
+	 */
+	var names []string
+	testedCtrl.QueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			var name string
+			ToRowsExt(rows).Scan(&name)
+			names = append(names, name)
+			return IterateContinue
+		}),
+		"SELECT c_name FROM car_dr01",
+	)
+	c.Assert(names, DeepEquals, []string{"c1"})
+	// :~)
+}
+
+// Tests the skipping of writes inside transaction in dry-run mode
+func (suite *TestRdbDryRunSuite) TestDryRunInTx(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.ExecQueriesInTx(
+		"CREATE TABLE car_dr02(c_id INT PRIMARY KEY)",
+		"INSERT INTO car_dr02 VALUES(1), (2), (3)",
+	)
+
+	var loggedSqls []string
+	testedCtrl.SetDryRunLogger(func(sql string, args []interface{}) {
+		loggedSqls = append(loggedSqls, sql)
+	})
+	testedCtrl.SetDryRun(true)
+
+	countInTx := func(txExt *TxExt) (count int) {
+		txExt.QueryForRow(
+			RowCallbackFunc(func(row *sql.Row) { ToRowExt(row).Scan(&count) }),
+			"SELECT COUNT(*) FROM car_dr02",
+		)
+		return
+	}
+
+	c.Assert(testedCtrl.ExecQueriesInTxCounting("DELETE FROM car_dr02"), DeepEquals, []int64{0})
+
+	/**
+	 * The table is unchanged inside of transaction
+	 */
+	var countInsideTx int
+	testedCtrl.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		txExt := ToTxExt(tx)
+		txExt.Exec("DELETE FROM car_dr02 WHERE c_id = ?", 1)
+		txExt.ExecMany("INSERT INTO car_dr02 VALUES(?)", [][]interface{}{{4}, {5}})
+
+		countInsideTx = countInTx(txExt)
+		return TxCommit
+	}))
+	c.Assert(countInsideTx, Equals, 3)
+
+	txExt := testedCtrl.Begin()
+	txExt.Exec("DELETE FROM car_dr02")
+	c.Assert(countInTx(txExt), Equals, 3)
+	txExt.Finish(nil)
+	// :~)
+
+	c.Assert(loggedSqls, DeepEquals, []string{
+		"DELETE FROM car_dr02", "COMMIT",
+		"DELETE FROM car_dr02 WHERE c_id = ?", "INSERT INTO car_dr02 VALUES(?)", "INSERT INTO car_dr02 VALUES(?)", "COMMIT",
+		"DELETE FROM car_dr02", "COMMIT",
+	})
+
+	var count int
+	testedCtrl.QueryForRow(
+		RowCallbackFunc(func(row *sql.Row) { ToRowExt(row).Scan(&count) }),
+		"SELECT COUNT(*) FROM car_dr02",
+	)
+	c.Assert(count, Equals, 3)
+}
@@ -1,12 +1,31 @@
 package db
 
 import (
+	"errors"
+	"fmt"
+
+	or "github.com/Cepave/open-falcon-backend/common/runtime"
 	"github.com/Cepave/open-falcon-backend/common/utils"
 )
 
 // Defines the type of database error
+//
+// If the error is raised by execution of SQL, the operation, the SQL and the arguments are kept
+// in this object, which could be retrieved by errors.As():
+//
+//	var dbErr *DbError
+//	if errors.As(err, &dbErr) {
+//		log.Printf("Failed SQL: %s", dbErr.SQL)
+//	}
 type DbError struct {
 	*utils.StackError
+
+	// The name of operation("exec" or "query"), empty if the error is not raised by execution of SQL
+	Op string
+	// The SQL text
+	SQL string
+	// The arguments of SQL, which are redacted by ArgRedactor if it is set
+	Args []interface{}
 }
 
 // Panic with database error if the error is vialbe
@@ -20,10 +39,53 @@ func PanicIfError(err error) {
 
 // Constructs an error of database
 func NewDatabaseError(err error) *DbError {
+	if dbError, ok := err.(*DbError); ok {
+		return dbError
+	}
+
 	stackError, ok := err.(*utils.StackError)
-	if ok {
-		return &DbError{stackError}
+	if !ok {
+		stackError = utils.BuildErrorWithCaller(err)
 	}
 
-	return &DbError{utils.BuildErrorWithCaller(err)}
+	dbError := &DbError{StackError: stackError}
+
+	/**
+	 * Keeps the information of SQL if the error is wrapped from another DbError
+	 */
+	var causeError *DbError
+	if errors.As(err, &causeError) {
+		dbError.Op, dbError.SQL, dbError.Args = causeError.Op, causeError.SQL, causeError.Args
+	}
+	// :~)
+
+	return dbError
+}
+
+// Constructs an error of database with the operation, the SQL and its arguments
+func newSqlError(op string, sql string, args []interface{}, err error, callerInfo *or.CallerInfo) *DbError {
+	redactedArgs := redactArgs(args)
+
+	return &DbError{
+		StackError: utils.BuildErrorWithCallerInfo(
+			fmt.Errorf("SQL of %s with exception: %w. SQL: \"%s\" Params: %#v", op, err, sql, redactedArgs),
+			callerInfo,
+		),
+		Op:   op,
+		SQL:  sql,
+		Args: redactedArgs,
+	}
+}
+
+// Builds a handler of panic, which captures the panic as error into the holder
+//
+// The holder would keep the first captured error.
+func NewDbErrorCapture(errHolder *error) utils.PanicHandler {
+	return func(p interface{}) {
+		if *errHolder != nil {
+			return
+		}
+
+		*errHolder = utils.SimpleErrorConverter(p)
+	}
 }
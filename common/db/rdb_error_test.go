@@ -0,0 +1,84 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestDbErrorSuite struct{}
+
+var _ = Suite(&TestDbErrorSuite{})
+
+// Tests the captured error exposes the operation and SQL
+func (suite *TestDbErrorSuite) TestCapturedDbError(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testCases := []*struct {
+		testedFunc   func(ctrl *DbController)
+		expectedOp   string
+		expectedSql  string
+		expectedArgs []interface{}
+	}{
+		{
+			func(ctrl *DbController) {
+				ctrl.QueryForRows(
+					RowsCallbackFunc(func(rows *sql.Rows) IterateControl { return IterateContinue }),
+					"SELECT * FROM no_such_table WHERE id = ?", 10,
+				)
+			},
+			"query", "SELECT * FROM no_such_table WHERE id = ?", []interface{}{10},
+		},
+		{
+			func(ctrl *DbController) {
+				ctrl.Exec("DELETE FROM no_such_table WHERE id = ?", 20)
+			},
+			"exec", "DELETE FROM no_such_table WHERE id = ?", []interface{}{20},
+		},
+		{
+			func(ctrl *DbController) {
+				ctrl.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+					ToTxExt(tx).Exec("UPDATE no_such_table SET name = ?", "v1")
+					return TxCommit
+				}))
+			},
+			"exec", "UPDATE no_such_table SET name = ?", []interface{}{"v1"},
+		},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		var err error
+		capturingCtrl := *testedCtrl
+		capturingCtrl.panicHandlers = nil
+		capturingCtrl.RegisterPanicHandler(NewDbErrorCapture(&err))
+
+		testCase.testedFunc(&capturingCtrl)
+
+		var dbErr *DbError
+		c.Assert(errors.As(err, &dbErr), Equals, true, comment)
+		c.Assert(dbErr.Op, Equals, testCase.expectedOp, comment)
+		c.Assert(dbErr.SQL, Equals, testCase.expectedSql, comment)
+		c.Assert(dbErr.Args, DeepEquals, testCase.expectedArgs, comment)
+		c.Assert(err, ErrorMatches, ".*no such table.*", comment)
+	}
+}
+
+// Tests the arguments kept in error are redacted
+func (suite *TestDbErrorSuite) TestRedactedArgs(c *C) {
+	ArgRedactor = RedactAll
+	defer func() { ArgRedactor = nil }()
+
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	_, err := testedCtrl.ExecE("UPDATE no_such_table SET password = ?", "my-secret")
+
+	var dbErr *DbError
+	c.Assert(errors.As(err, &dbErr), Equals, true)
+	c.Assert(dbErr.Args, DeepEquals, []interface{}{"***"})
+	c.Assert(err, Not(ErrorMatches), ".*my-secret.*")
+}
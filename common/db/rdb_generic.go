@@ -0,0 +1,97 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	or "github.com/Cepave/open-falcon-backend/common/runtime"
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// Query for all of the rows and converts every row to value of type T by the scan function
+//
+// The scan function may use RowsExt.Scan(), which panics if there is an error of scanning.
+func QueryAll[T any](c *DbController, scan func(*RowsExt) T, sqlQuery string, args ...interface{}) []T {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	result := make([]T, 0)
+	c.QueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			result = append(result, scan(ToRowsExt(rows)))
+			return IterateContinue
+		}),
+		sqlQuery, args...,
+	)
+
+	return result
+}
+
+// Query for all of the rows and appends them to the slice, every row is scanned into the appended zero value of type T
+//
+// The existing elements of slice are kept, so the slice could be pre-sized by capacity:
+//
+//	cars := make([]car, 0, 16)
+//	QueryInto(dbController, &cars, func(rows *RowsExt, c *car) { rows.Scan(&c.id, &c.name) }, "SELECT c_id, c_name FROM car")
+func QueryInto[T any](c *DbController, out *[]T, scan func(*RowsExt, *T), sqlQuery string, args ...interface{}) {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	c.QueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			var zero T
+			*out = append(*out, zero)
+			scan(ToRowsExt(rows), &(*out)[len(*out)-1])
+			return IterateContinue
+		}),
+		sqlQuery, args...,
+	)
+}
+
+// Query for the first row and converts it to value of type T by the scan function
+//
+// This function panics if there is no row found(the error wraps sql.ErrNoRows),
+// which could be captured by registered PanicHandlers.
+func QueryOne[T any](c *DbController, scan func(*RowsExt) T, sqlQuery string, args ...interface{}) T {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	var result T
+	numberOfRows := c.QueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			result = scan(ToRowsExt(rows))
+			return IterateStop
+		}),
+		sqlQuery, args...,
+	)
+
+	if numberOfRows == 0 {
+		func() {
+			defer c.handlePanic()
+
+			PanicIfError(newSqlError(
+				"query", sqlQuery, args,
+				fmt.Errorf("Query for one row but nothing found: %w", sql.ErrNoRows),
+				or.GetCallerInfo(),
+			))
+		}()
+	}
+
+	return result
+}
+
+// Executes the function in transaction and gets its value
+//
+// The transaction is committed if the function returns normally, rollbacked if there is a panic.
+//
+//	newId := InTxValue(dbController, func(tx *TxExt) int64 {
+//		return ToResultExt(tx.Exec("INSERT INTO nqm_target(tg_name) VALUES(?)", name)).LastInsertId()
+//	})
+func InTxValue[T any](c *DbController, fn func(*TxExt) T) T {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	var result T
+	c.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		result = fn(ToTxExt(tx))
+		return TxCommit
+	}))
+
+	return result
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbGenericSuite struct{}
+
+var _ = Suite(&TestRdbGenericSuite{})
+
+type sampleCar struct {
+	id   int
+	name string
+}
+
+func scanSampleCar(rows *RowsExt) *sampleCar {
+	car := &sampleCar{}
+	rows.Scan(&car.id, &car.name)
+	return car
+}
+
+// Tests the query for all rows with typed result
+func (suite *TestRdbGenericSuite) TestQueryAll(c *C) {
+	testedCtrl := buildSampleCarDbController(c)
+	defer testedCtrl.Release()
+
+	testedResult := QueryAll(testedCtrl, scanSampleCar, "SELECT c_id, c_name FROM car_g01 ORDER BY c_id")
+
+	c.Assert(testedResult, HasLen, 3)
+	c.Assert(testedResult[0], DeepEquals, &sampleCar{1, "car-1"})
+	c.Assert(testedResult[2], DeepEquals, &sampleCar{3, "car-3"})
+
+	c.Assert(
+		QueryAll(testedCtrl, scanSampleCar, "SELECT c_id, c_name FROM car_g01 WHERE c_id > 10"),
+		HasLen, 0,
+	)
+}
+
+// Tests the query for rows appended to the slice
+func (suite *TestRdbGenericSuite) TestQueryInto(c *C) {
+	testedCtrl := buildSampleCarDbController(c)
+	defer testedCtrl.Release()
+
+	scanCar := func(rows *RowsExt, car *sampleCar) {
+		rows.Scan(&car.id, &car.name)
+	}
+
+	testedResult := make([]sampleCar, 0, 4)
+	QueryInto(testedCtrl, &testedResult, scanCar, "SELECT c_id, c_name FROM car_g01 ORDER BY c_id")
+
+	c.Assert(testedResult, DeepEquals, []sampleCar{{1, "car-1"}, {2, "car-2"}, {3, "car-3"}})
+
+	/**
+	 * The existing elements are kept
+	 */
+	QueryInto(testedCtrl, &testedResult, scanCar, "SELECT c_id, c_name FROM car_g01 WHERE c_id = ?", 2)
+	c.Assert(testedResult, HasLen, 4)
+	c.Assert(testedResult[3], DeepEquals, sampleCar{2, "car-2"})
+
+	QueryInto(testedCtrl, &testedResult, scanCar, "SELECT c_id, c_name FROM car_g01 WHERE c_id > 10")
+	c.Assert(testedResult, HasLen, 4)
+	// :~)
+}
+
+// Tests the query for one row with typed result
+func (suite *TestRdbGenericSuite) TestQueryOne(c *C) {
+	testedCtrl := buildSampleCarDbController(c)
+	defer testedCtrl.Release()
+
+	testedResult := QueryOne(testedCtrl, scanSampleCar, "SELECT c_id, c_name FROM car_g01 WHERE c_id = ?", 2)
+	c.Assert(testedResult, DeepEquals, &sampleCar{2, "car-2"})
+
+	c.Assert(
+		func() {
+			QueryOne(testedCtrl, scanSampleCar, "SELECT c_id, c_name FROM car_g01 WHERE c_id = ?", 20)
+		},
+		PanicMatches, ".*nothing found.*",
+	)
+
+	/**
+	 * The error is captured by PanicHandlers with redacted arguments
+	 */
+	ArgRedactor = RedactAll
+	defer func() { ArgRedactor = nil }()
+
+	var err error
+	testedCtrl.RegisterPanicHandler(NewDbErrorCapture(&err))
+
+	QueryOne(testedCtrl, scanSampleCar, "SELECT c_id, c_name FROM car_g01 WHERE c_name = ?", "my-secret")
+	c.Assert(errors.Is(err, sql.ErrNoRows), Equals, true)
+	c.Assert(err, Not(ErrorMatches), ".*my-secret.*")
+	// :~)
+}
+
+// Tests the value returned from transaction
+func (suite *TestRdbGenericSuite) TestInTxValue(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec("CREATE TABLE car_g02(c_id INTEGER PRIMARY KEY AUTOINCREMENT, c_name VARCHAR(64) NOT NULL)")
+
+	for i, expectedId := range []int64{1, 2} {
+		testedId := InTxValue(testedCtrl, func(tx *TxExt) int64 {
+			return ToResultExt(tx.Exec("INSERT INTO car_g02(c_name) VALUES(?)", "car-g02")).LastInsertId()
+		})
+		c.Assert(testedId, Equals, expectedId, Commentf("Test Case: %d", i+1))
+	}
+
+	/**
+	 * Rollback when there is panic
+	 */
+	c.Assert(
+		func() {
+			InTxValue(testedCtrl, func(tx *TxExt) int64 {
+				tx.Exec("INSERT INTO car_g02(c_name) VALUES(?)", "car-g02")
+				panic("Rollback sample")
+			})
+		},
+		PanicMatches, ".*Rollback sample.*",
+	)
+
+	var numberOfCars int
+	testedCtrl.QueryForScalar(&numberOfCars, "SELECT COUNT(*) FROM car_g02")
+	c.Assert(numberOfCars, Equals, 2)
+	// :~)
+}
+
+func buildSampleCarDbController(c *C) *DbController {
+	testedCtrl := buildSampleDbController(c)
+
+	testedCtrl.Exec("CREATE TABLE car_g01(c_id INT PRIMARY KEY, c_name VARCHAR(64) NOT NULL)")
+	testedCtrl.Exec("INSERT INTO car_g01 VALUES(1, 'car-1'), (2, 'car-2'), (3, 'car-3')")
+
+	return testedCtrl
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// Expands the placeholders of slice arguments for IN clause
+//
+// Every slice argument(except []byte) expands its corresponding "?" to multiple ones and
+// its elements are flattened into the returned arguments:
+//
+//	ExpandIn("SELECT * FROM nqm_agent WHERE ag_id IN (?) AND ag_status = ?", []int{1, 2, 3}, true)
+//	// "SELECT * FROM nqm_agent WHERE ag_id IN (?,?,?) AND ag_status = ?", [1, 2, 3, true]
+//
+// The panic is raised if a slice is empty(since "IN ()" is invalid SQL) or
+// the number of placeholders doesn't match the number of arguments.
+func ExpandIn(query string, args ...interface{}) (string, []interface{}) {
+	var result strings.Builder
+	expandedArgs := make([]interface{}, 0, len(args))
+
+	argIndex := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := endOfQuoted(query, i)
+			result.WriteString(query[i:end])
+			i = end - 1
+		case c == '?':
+			if argIndex >= len(args) {
+				PanicIfError(utils.BuildErrorWithCaller(
+					fmt.Errorf("Number of arguments(%d) is less than placeholders. SQL: \"%s\"", len(args), query),
+				))
+			}
+
+			expandedArgs = expandInArg(&result, expandedArgs, args[argIndex], argIndex, query)
+			argIndex++
+		default:
+			result.WriteByte(c)
+		}
+	}
+
+	if argIndex != len(args) {
+		PanicIfError(utils.BuildErrorWithCaller(
+			fmt.Errorf("Number of arguments(%d) is more than placeholders(%d). SQL: \"%s\"", len(args), argIndex, query),
+		))
+	}
+
+	return result.String(), expandedArgs
+}
+
+func expandInArg(result *strings.Builder, expandedArgs []interface{}, arg interface{}, argIndex int, query string) []interface{} {
+	value := reflect.ValueOf(arg)
+	if _, isBytes := arg.([]byte); isBytes ||
+		!value.IsValid() ||
+		(value.Kind() != reflect.Slice && value.Kind() != reflect.Array) {
+		result.WriteByte('?')
+		return append(expandedArgs, arg)
+	}
+
+	if value.Len() == 0 {
+		PanicIfError(utils.BuildErrorWithCaller(
+			fmt.Errorf("Slice argument #%d is empty for IN clause. SQL: \"%s\"", argIndex+1, query),
+		))
+	}
+
+	for i := 0; i < value.Len(); i++ {
+		if i > 0 {
+			result.WriteByte(',')
+		}
+		result.WriteByte('?')
+		expandedArgs = append(expandedArgs, value.Index(i).Interface())
+	}
+
+	return expandedArgs
+}
+
+// Queries for rows with slice arguments expanded by ExpandIn
+func (dbController *DbController) QueryForRowsIn(
+	rowsCallback RowsCallback,
+	sqlQuery string, args ...interface{},
+) uint {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	expandedQuery, expandedArgs := ExpandIn(sqlQuery, args...)
+	return dbController.QueryForRows(rowsCallback, expandedQuery, expandedArgs...)
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"database/sql"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbInSuite struct{}
+
+var _ = Suite(&TestRdbInSuite{})
+
+// Tests the expanding of slice arguments
+func (suite *TestRdbInSuite) TestExpandIn(c *C) {
+	testCases := []*struct {
+		query         string
+		args          []interface{}
+		expectedQuery string
+		expectedArgs  []interface{}
+	}{
+		{
+			"SELECT * FROM car WHERE c_id IN (?)",
+			[]interface{}{[]int{1, 2, 3}},
+			"SELECT * FROM car WHERE c_id IN (?,?,?)",
+			[]interface{}{1, 2, 3},
+		},
+		{ // Mixed scalar and slice arguments
+			"SELECT * FROM car WHERE c_a = ? AND c_id IN (?) AND c_name IN (?) AND c_b = ?",
+			[]interface{}{"a", []int64{5}, []string{"n1", "n2"}, nil},
+			"SELECT * FROM car WHERE c_a = ? AND c_id IN (?) AND c_name IN (?,?) AND c_b = ?",
+			[]interface{}{"a", int64(5), "n1", "n2", nil},
+		},
+		{ // []byte is not expanded and the "?" in quoted literal is kept
+			"SELECT '?' FROM car WHERE c_data = ?",
+			[]interface{}{[]byte("ab")},
+			"SELECT '?' FROM car WHERE c_data = ?",
+			[]interface{}{[]byte("ab")},
+		},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		testedQuery, testedArgs := ExpandIn(testCase.query, testCase.args...)
+		c.Assert(testedQuery, Equals, testCase.expectedQuery, comment)
+		c.Assert(testedArgs, DeepEquals, testCase.expectedArgs, comment)
+	}
+}
+
+// Tests the panic of invalid arguments
+func (suite *TestRdbInSuite) TestExpandInWithInvalidArgs(c *C) {
+	c.Assert(
+		func() { ExpandIn("SELECT * FROM car WHERE c_id IN (?)", []int{}) },
+		PanicMatches, ".*empty.*",
+	)
+	c.Assert(
+		func() { ExpandIn("SELECT * FROM car WHERE c_id IN (?) AND c_a = ?", []int{1}) },
+		PanicMatches, ".*less than placeholders.*",
+	)
+	c.Assert(
+		func() { ExpandIn("SELECT * FROM car WHERE c_id = ?", 1, 2) },
+		PanicMatches, ".*more than placeholders.*",
+	)
+}
+
+// Tests the querying with slice arguments
+func (suite *TestRdbInSuite) TestQueryForRowsIn(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.ExecQueriesInTx(
+		"CREATE TABLE car_in01(c_id INT PRIMARY KEY, c_name VARCHAR(64))",
+		"INSERT INTO car_in01 VALUES(1, 'c1'), (2, 'c2'), (3, 'c3'), (4, 'c4')",
+	)
+
+	var testedIds []int
+	numberOfRows := testedCtrl.QueryForRowsIn(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			var id int
+			ToRowsExt(rows).Scan(&id)
+			testedIds = append(testedIds, id)
+			return IterateContinue
+		}),
+		"SELECT c_id FROM car_in01 WHERE c_id IN (?) AND c_name <> ? ORDER BY c_id",
+		[]int{1, 2, 4}, "c2",
+	)
+
+	c.Assert(numberOfRows, Equals, uint(2))
+	c.Assert(testedIds, DeepEquals, []int{1, 4})
+}
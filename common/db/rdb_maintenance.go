@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	or "github.com/Cepave/open-falcon-backend/common/runtime"
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// Executes the statements of maintenance(e.g. "ANALYZE TABLE" or "OPTIMIZE TABLE") in order
+//
+// Every statement is executed outside of transaction with the context,
+// which is not affected by SetDefaultQueryTimeout(), so the context should be given with a long deadline:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+//	defer cancel()
+//
+//	dbController.Maintenance(ctx, "ANALYZE TABLE nqm_log", "OPTIMIZE TABLE nqm_log")
+//
+// The progress is logged for every statement. The failed statement raises panic and the remaining ones are skipped.
+func (dbController *DbController) Maintenance(ctx context.Context, statements ...string) {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	if dbController.dryRun {
+		for _, statement := range statements {
+			dbController.logDryRun(statement, nil)
+		}
+		return
+	}
+
+	var dbFunc DbCallbackFunc = func(db *sql.DB) {
+		for i, statement := range statements {
+			Logger.Printf("[Maintenance] (%d/%d) Executing: \"%s\"", i+1, len(statements), statement)
+
+			startTime := time.Now()
+			_, err := dbController.execOnDb(ctx, db, statement, nil)
+			if err != nil {
+				Logger.Printf("[Maintenance] (%d/%d) Failed. Elapsed: [%v]. Error: %v", i+1, len(statements), time.Since(startTime), err)
+				PanicIfError(newSqlError("exec", statement, nil, err, or.GetCallerInfo()))
+			}
+
+			Logger.Printf("[Maintenance] (%d/%d) Finished. Elapsed: [%v]", i+1, len(statements), time.Since(startTime))
+		}
+	}
+
+	dbController.OperateOnDb(dbFunc)
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"log"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbMaintenanceSuite struct{}
+
+var _ = Suite(&TestRdbMaintenanceSuite{})
+
+// Tests the executing of maintenance statements in order and outside of transaction
+func (suite *TestRdbMaintenanceSuite) TestMaintenance(c *C) {
+	var inTx []bool
+	var hasDeadline []bool
+	fakeDb := &fakeDriverDb{
+		execFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Result, error) {
+			inTx = append(inTx, conn.inTx)
+
+			_, ok := conn.ctx.Deadline()
+			hasDeadline = append(hasDeadline, ok)
+
+			return driver.RowsAffected(0), nil
+		},
+	}
+	testedCtrl := newFakeDbController(fakeDb)
+	defer testedCtrl.Release()
+
+	logger := &capturingLogger{}
+	Logger = logger
+	defer func() { Logger = log.Default() }()
+
+	testedCtrl.SetDefaultQueryTimeout(time.Second)
+	testedCtrl.Maintenance(
+		context.Background(),
+		"ANALYZE TABLE nqm_log", "OPTIMIZE TABLE nqm_log", "ANALYZE TABLE nqm_target",
+	)
+
+	c.Assert(fakeDb.executed(), DeepEquals, []string{
+		"ANALYZE TABLE nqm_log", "OPTIMIZE TABLE nqm_log", "ANALYZE TABLE nqm_target",
+	})
+	c.Assert(inTx, DeepEquals, []bool{false, false, false})
+	c.Assert(hasDeadline, DeepEquals, []bool{false, false, false})
+	c.Assert(logger.messages, HasLen, 6)
+	c.Assert(logger.messages[5], Matches, `\[Maintenance\] \(3/3\) Finished.*`)
+}
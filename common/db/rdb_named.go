@@ -0,0 +1,102 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// Executes the query with ":name" style parameters
+//
+// See NamedQueryForRows for the syntax of parameters.
+func (dbController *DbController) NamedExec(query string, arg map[string]interface{}) sql.Result {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	positionalQuery, args := bindNamed(query, arg)
+	return dbController.Exec(positionalQuery, args...)
+}
+
+// Queries for rows with ":name" style parameters
+//
+// The ":name" is rewritten to "?" with the value of arg[name], the same name could be used repeatedly:
+//
+//	SELECT * FROM nqm_agent WHERE ag_name = :name OR ag_hostname = :name
+//
+// The "::" is a literal ":" and the names inside quoted literals are not substituted.
+//
+// The panic is raised if a name cannot be found in arg.
+func (dbController *DbController) NamedQueryForRows(
+	rowsCallback RowsCallback,
+	query string, arg map[string]interface{},
+) uint {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	positionalQuery, args := bindNamed(query, arg)
+	return dbController.QueryForRows(rowsCallback, positionalQuery, args...)
+}
+
+// Rewrites ":name" to "?" and builds the positional arguments
+func bindNamed(query string, arg map[string]interface{}) (string, []interface{}) {
+	var result strings.Builder
+	var args []interface{}
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := endOfQuoted(query, i)
+			result.WriteString(query[i:end])
+			i = end - 1
+		case c == ':' && i+1 < len(query) && query[i+1] == ':':
+			result.WriteByte(':')
+			i++
+		case c == ':' && i+1 < len(query) && isNameChar(query[i+1]):
+			end := i + 1
+			for end < len(query) && isNameChar(query[end]) {
+				end++
+			}
+
+			name := query[i+1 : end]
+			value, ok := arg[name]
+			if !ok {
+				PanicIfError(utils.BuildErrorWithCaller(
+					fmt.Errorf("Named parameter cannot be found: \"%s\". SQL: \"%s\"", name, query),
+				))
+			}
+
+			result.WriteByte('?')
+			args = append(args, value)
+			i = end - 1
+		default:
+			result.WriteByte(c)
+		}
+	}
+
+	return result.String(), args
+}
+
+// Gets the index after the closing quote, the backslash escapes the following character
+func endOfQuoted(query string, start int) int {
+	quote := query[start]
+
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+
+	return len(query)
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' ||
+		(c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9')
+}
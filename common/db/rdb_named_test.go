@@ -0,0 +1,86 @@
+package db
+
+import (
+	"database/sql"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbNamedSuite struct{}
+
+var _ = Suite(&TestRdbNamedSuite{})
+
+// Tests the rewriting of named parameters
+func (suite *TestRdbNamedSuite) TestBindNamed(c *C) {
+	arg := map[string]interface{}{
+		"id":   10,
+		"name": "car-1",
+	}
+
+	testCases := []*struct {
+		query         string
+		expectedQuery string
+		expectedArgs  []interface{}
+	}{
+		{
+			"SELECT * FROM car WHERE c_id = :id",
+			"SELECT * FROM car WHERE c_id = ?",
+			[]interface{}{10},
+		},
+		{ // Repeated names
+			"SELECT * FROM car WHERE c_name = :name OR c_alias = :name AND c_id > :id",
+			"SELECT * FROM car WHERE c_name = ? OR c_alias = ? AND c_id > ?",
+			[]interface{}{"car-1", "car-1", 10},
+		},
+		{ // Names inside quoted literals
+			"SELECT ':name', \"it\\\":id\", `:id` FROM car WHERE c_name = :name",
+			"SELECT ':name', \"it\\\":id\", `:id` FROM car WHERE c_name = ?",
+			[]interface{}{"car-1"},
+		},
+		{ // Escaped colon
+			"SELECT CAST(c_id AS TEXT) || '::' || :name, 10::id, a: 1 FROM car",
+			"SELECT CAST(c_id AS TEXT) || '::' || ?, 10:id, a: 1 FROM car",
+			[]interface{}{"car-1"},
+		},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		testedQuery, testedArgs := bindNamed(testCase.query, arg)
+		c.Assert(testedQuery, Equals, testCase.expectedQuery, comment)
+		c.Assert(testedArgs, DeepEquals, testCase.expectedArgs, comment)
+	}
+
+	c.Assert(
+		func() { bindNamed("SELECT * FROM car WHERE c_id = :no_such_name", arg) },
+		PanicMatches, ".*no_such_name.*",
+	)
+}
+
+// Tests the executing and querying with named parameters
+func (suite *TestRdbNamedSuite) TestNamedExecAndQuery(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec("CREATE TABLE car_n01(c_id INT PRIMARY KEY, c_name VARCHAR(64), c_alias VARCHAR(64))")
+
+	result := testedCtrl.NamedExec(
+		"INSERT INTO car_n01 VALUES(:id, :name, :name || ':alias')",
+		map[string]interface{}{"id": 1, "name": "car-1"},
+	)
+	c.Assert(ToResultExt(result).RowsAffected(), Equals, int64(1))
+
+	var testedAlias string
+	numberOfRows := testedCtrl.NamedQueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			ToRowsExt(rows).Scan(&testedAlias)
+			return IterateContinue
+		}),
+		"SELECT c_alias FROM car_n01 WHERE c_name = :name AND c_id = :id",
+		map[string]interface{}{"id": 1, "name": "car-1"},
+	)
+
+	c.Assert(numberOfRows, Equals, uint(1))
+	c.Assert(testedAlias, Equals, "car-1:alias")
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// The observer of queries, which may be used to collect metrics of database
+//
+// The "op" could be:
+//
+//	"exec" - Executing of statement
+//	"query" - Query for rows or a row
+//	"tx" - The whole transaction, the "sql" is empty
+//
+// The "err" is nil if the operation is successful.
+type QueryObserver interface {
+	ObserveQuery(op string, sql string, elapsed time.Duration, err error)
+}
+
+// The optional interface of QueryObserver, which gets the outcome of every transaction
+//
+// The "result" is TxCommit only if the transaction is committed successfully,
+// otherwise it is TxRollback(including the panicked transaction, which has non-nil "err").
+type TxObserver interface {
+	ObserveTx(result TxFinale, elapsed time.Duration, err error)
+}
+
+// The optional interface of QueryObserver, which gets the labels of controller built by WithLabels()
+//
+// If the observer implements this interface, this method is called instead of ObserveQuery().
+// The "labels" is nil if the controller has no label.
+type LabeledQueryObserver interface {
+	ObserveLabeledQuery(labels map[string]string, op string, sql string, elapsed time.Duration, err error)
+}
+
+// Builds a view of this controller, which gives the labels to observer for attribution of queries
+//
+// The pool of connections is shared with this controller,
+// the observer and other settings are copied at the time of calling.
+// The labels are merged with the ones of this controller(the new ones win).
+//
+//	agentDb := dbController.WithLabels(map[string]string{"subsystem": "agent"})
+func (dbController *DbController) WithLabels(labels map[string]string) *DbController {
+	mergedLabels := make(map[string]string, len(dbController.labels)+len(labels))
+	for k, v := range dbController.labels {
+		mergedLabels[k] = v
+	}
+	for k, v := range labels {
+		mergedLabels[k] = v
+	}
+
+	labeledCtrl := *dbController
+	labeledCtrl.panicHandlers = append([]PanicFilter{}, dbController.panicHandlers...)
+	labeledCtrl.labels = mergedLabels
+
+	return &labeledCtrl
+}
+
+// Sets the observer for queries, the nil value disables the observing
+func (dbController *DbController) SetQueryObserver(o QueryObserver) {
+	dbController.queryObserver = o
+}
+
+// Sets the rate of sampling for observer, only 1-in-n successful operations are observed
+//
+// The failed operations are always observed regardless of sampling,
+// so only the numbers of successful operations should be scaled(by ObserverSampleRate()).
+//
+// The sampling is disabled if "n" is less than or equal to 1.
+func (dbController *DbController) SetObserverSampleRate(n int) {
+	if n <= 1 {
+		n = 1
+	}
+
+	dbController.observerSampleRate = n
+	dbController.observerCounter = new(uint64)
+}
+
+// Gets the rate of sampling for observer, which is 1 if the sampling is disabled
+func (dbController *DbController) ObserverSampleRate() int {
+	if dbController.observerSampleRate <= 1 {
+		return 1
+	}
+
+	return dbController.observerSampleRate
+}
+
+// Decides whether or not the operation is observed by the rate of sampling
+func (dbController *DbController) isSampled(err error) bool {
+	if err != nil || dbController.observerSampleRate <= 1 {
+		return true
+	}
+
+	return (atomic.AddUint64(dbController.observerCounter, 1)-1)%uint64(dbController.observerSampleRate) == 0
+}
+
+func (dbController *DbController) observeQuery(op string, sql string, startTime time.Time, err error) {
+	if dbController.queryObserver == nil || !dbController.isSampled(err) {
+		return
+	}
+
+	dbController.notifyObserver(op, sql, time.Since(startTime), err)
+}
+
+func (dbController *DbController) notifyObserver(op string, sql string, elapsed time.Duration, err error) {
+	if labeledObserver, ok := dbController.queryObserver.(LabeledQueryObserver); ok {
+		labeledObserver.ObserveLabeledQuery(dbController.labels, op, sql, elapsed, err)
+		return
+	}
+
+	dbController.queryObserver.ObserveQuery(op, sql, elapsed, err)
+}
+
+// This function should be called by defer, the raised panic would be re-paniced
+func (dbController *DbController) observeTx(startTime time.Time, finale *TxFinale) {
+	p := recover()
+
+	var err error
+	if p != nil {
+		err = utils.SimpleErrorConverter(p)
+	}
+
+	if dbController.isSampled(err) {
+		elapsed := time.Since(startTime)
+		dbController.notifyObserver("tx", "", elapsed, err)
+		if txObserver, ok := dbController.queryObserver.(TxObserver); ok {
+			txObserver.ObserveTx(*finale, elapsed, err)
+		}
+	}
+
+	if p != nil {
+		panic(p)
+	}
+}
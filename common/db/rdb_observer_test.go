@@ -0,0 +1,173 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbObserverSuite struct{}
+
+var _ = Suite(&TestRdbObserverSuite{})
+
+type observedEvent struct {
+	op      string
+	sql     string
+	success bool
+}
+
+type fakeObserver struct {
+	events []*observedEvent
+}
+
+func (o *fakeObserver) ObserveQuery(op string, sql string, elapsed time.Duration, err error) {
+	o.events = append(o.events, &observedEvent{op, sql, err == nil})
+}
+
+// Tests the observing of successful and failed operations
+func (suite *TestRdbObserverSuite) TestObserveQuery(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec("CREATE TABLE test_observer(to_id INT PRIMARY KEY)")
+
+	testedObserver := &fakeObserver{}
+	testedCtrl.SetQueryObserver(testedObserver)
+	testedCtrl.RegisterPanicHandler(func(p interface{}) {})
+
+	testedCtrl.Exec("INSERT INTO test_observer VALUES(1)")
+	testedCtrl.Exec("INSERT INTO no_such_table VALUES(1)")
+	testedCtrl.QueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl { return IterateContinue }),
+		"SELECT * FROM test_observer",
+	)
+	testedCtrl.QueryForRow(
+		RowCallbackFunc(func(row *sql.Row) {}),
+		"SELECT * FROM no_such_table",
+	)
+	testedCtrl.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		return TxCommit
+	}))
+	testedCtrl.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		ToTxExt(tx).Exec("INSERT INTO no_such_table VALUES(1)")
+		return TxCommit
+	}))
+
+	c.Assert(testedObserver.events, DeepEquals, []*observedEvent{
+		{"exec", "INSERT INTO test_observer VALUES(1)", true},
+		{"exec", "INSERT INTO no_such_table VALUES(1)", false},
+		{"query", "SELECT * FROM test_observer", true},
+		{"query", "SELECT * FROM no_such_table", false},
+		{"tx", "", true},
+		{"tx", "", false},
+	})
+}
+
+type fakeTxObserver struct {
+	fakeObserver
+	finales []TxFinale
+	errs    []bool
+}
+
+func (o *fakeTxObserver) ObserveTx(result TxFinale, elapsed time.Duration, err error) {
+	o.finales = append(o.finales, result)
+	o.errs = append(o.errs, err != nil)
+}
+
+// Tests the observing of outcomes of transactions
+func (suite *TestRdbObserverSuite) TestObserveTx(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedObserver := &fakeTxObserver{}
+	testedCtrl.SetQueryObserver(testedObserver)
+	testedCtrl.RegisterPanicHandler(func(p interface{}) {})
+
+	testedCtrl.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		return TxCommit
+	}))
+	testedCtrl.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		return TxRollback
+	}))
+	testedCtrl.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		ToTxExt(tx).Exec("INSERT INTO no_such_table VALUES(1)")
+		return TxCommit
+	}))
+
+	c.Assert(testedObserver.finales, DeepEquals, []TxFinale{TxCommit, TxRollback, TxRollback})
+	c.Assert(testedObserver.errs, DeepEquals, []bool{false, false, true})
+	c.Assert(testedObserver.events, HasLen, 3)
+}
+
+type fakeLabeledObserver struct {
+	fakeObserver
+	labels []map[string]string
+}
+
+func (o *fakeLabeledObserver) ObserveLabeledQuery(labels map[string]string, op string, sql string, elapsed time.Duration, err error) {
+	o.labels = append(o.labels, labels)
+	o.ObserveQuery(op, sql, elapsed, err)
+}
+
+// Tests the labels given to observer
+func (suite *TestRdbObserverSuite) TestWithLabels(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedObserver := &fakeLabeledObserver{}
+	testedCtrl.SetQueryObserver(testedObserver)
+
+	agentCtrl := testedCtrl.WithLabels(map[string]string{"subsystem": "agent", "team": "nqm"})
+	targetCtrl := agentCtrl.WithLabels(map[string]string{"subsystem": "target"})
+
+	testedCtrl.Exec("CREATE TABLE test_labels(tl_id INT PRIMARY KEY)")
+	agentCtrl.Exec("INSERT INTO test_labels VALUES(1)")
+	targetCtrl.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		return TxCommit
+	}))
+
+	c.Assert(testedObserver.labels, DeepEquals, []map[string]string{
+		nil,
+		{"subsystem": "agent", "team": "nqm"},
+		{"subsystem": "target", "team": "nqm"},
+	})
+	c.Assert(testedObserver.events, HasLen, 3)
+}
+
+// Tests the sampling of successful operations, while the failed ones are always observed
+func (suite *TestRdbObserverSuite) TestObserverSampleRate(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedObserver := &fakeObserver{}
+	testedCtrl.SetQueryObserver(testedObserver)
+	testedCtrl.SetObserverSampleRate(10)
+	testedCtrl.RegisterPanicHandler(func(p interface{}) {})
+
+	c.Assert(testedCtrl.ObserverSampleRate(), Equals, 10)
+
+	for i := 0; i < 100; i++ {
+		testedCtrl.QueryForRow(RowCallbackFunc(func(row *sql.Row) {}), "SELECT 1")
+		testedCtrl.Exec("INSERT INTO no_such_table VALUES(1)")
+	}
+
+	var successes, failures int
+	for _, event := range testedObserver.events {
+		if event.success {
+			successes++
+		} else {
+			failures++
+		}
+	}
+
+	c.Assert(successes, Equals, 10)
+	c.Assert(failures, Equals, 100)
+
+	/**
+	 * Disables the sampling
+	 */
+	testedCtrl.SetObserverSampleRate(0)
+	c.Assert(testedCtrl.ObserverSampleRate(), Equals, 1)
+	// :~)
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// Runs the independent tasks on the controller in parallel, at most "maxConcurrency" tasks at the same time
+//
+// Queries should be performed inside every task(the *sql.Rows or *sql.Tx should not be shared across tasks),
+// the connection pool of controller gives every task its own connection.
+//
+// This method returns after all of the tasks are finished.
+// The panics raised by tasks are collected and raised as a combined error.
+func (dbController *DbController) QueryParallel(maxConcurrency int, tasks ...func(*DbController)) {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	if maxConcurrency < 1 {
+		PanicIfError(utils.BuildErrorWithCaller(
+			fmt.Errorf("The max concurrency must be greater than 0. Got: %d", maxConcurrency),
+		))
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	taskErrors := make([]error, len(tasks))
+	tokens := make(chan struct{}, maxConcurrency)
+
+	for i, task := range tasks {
+		tokens <- struct{}{}
+		wg.Add(1)
+
+		go func(i int, task func(*DbController)) {
+			defer func() {
+				if p := recover(); p != nil {
+					lock.Lock()
+					taskErrors[i] = fmt.Errorf("Task[%d] has error: %w", i, utils.SimpleErrorConverter(p))
+					lock.Unlock()
+				}
+
+				<-tokens
+				wg.Done()
+			}()
+
+			task(dbController)
+		}(i, task)
+	}
+
+	wg.Wait()
+
+	PanicIfError(errors.Join(taskErrors...))
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"sync/atomic"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbParallelSuite struct{}
+
+var _ = Suite(&TestRdbParallelSuite{})
+
+// Tests the running of queries in parallel with bound of concurrency
+func (suite *TestRdbParallelSuite) TestQueryParallel(c *C) {
+	testCases := []*struct {
+		maxConcurrency        int
+		expectedMaxConcurrent int32
+	}{
+		{3, 3},
+		{1, 1},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		var running, maxRunning int32
+		testedCtrl := newFakeDbController(&fakeDriverDb{
+			queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+				current := atomic.AddInt32(&running, 1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+						break
+					}
+				}
+
+				time.Sleep(30 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+
+				return &fakeRows{columns: []string{"v"}, values: [][]driver.Value{{int64(len(query))}}}, nil
+			},
+		})
+
+		results := make([]int, 3)
+		queryTask := func(index int, sqlQuery string) func(*DbController) {
+			return func(ctrl *DbController) {
+				ctrl.QueryForRow(
+					RowCallbackFunc(func(row *sql.Row) { ToRowExt(row).Scan(&results[index]) }),
+					sqlQuery,
+				)
+			}
+		}
+
+		testedCtrl.QueryParallel(
+			testCase.maxConcurrency,
+			queryTask(0, "SELECT 1"), queryTask(1, "SELECT 22"), queryTask(2, "SELECT 333"),
+		)
+
+		c.Assert(results, DeepEquals, []int{8, 9, 10}, comment)
+		c.Assert(atomic.LoadInt32(&maxRunning), Equals, testCase.expectedMaxConcurrent, comment)
+
+		testedCtrl.Release()
+	}
+}
+
+// Tests the combined error of failed tasks
+func (suite *TestRdbParallelSuite) TestQueryParallelWithPanic(c *C) {
+	testedCtrl := newFakeDbController(&fakeDriverDb{})
+	defer testedCtrl.Release()
+
+	var finished int32
+	c.Assert(
+		func() {
+			testedCtrl.QueryParallel(
+				2,
+				func(ctrl *DbController) { panic("error-1") },
+				func(ctrl *DbController) { atomic.AddInt32(&finished, 1) },
+				func(ctrl *DbController) { panic("error-3") },
+			)
+		},
+		PanicMatches, `(?s).*Task\[0\] has error: error-1.*Task\[2\] has error: error-3.*`,
+	)
+	c.Assert(atomic.LoadInt32(&finished), Equals, int32(1))
+}
@@ -0,0 +1,261 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	or "github.com/Cepave/open-falcon-backend/common/runtime"
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// Query for single value(the first column of the first row)
+//
+// Returns false if there is no row found, the "dest" is kept untouched.
+//
+// The error of scanning(e.g. type mismatch) would cause panic.
+func (dbController *DbController) QueryForScalar(dest interface{}, sqlQuery string, args ...interface{}) bool {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	found := false
+	dbController.QueryForRow(
+		RowCallbackFunc(func(row *sql.Row) {
+			err := row.Scan(dest)
+			if err == sql.ErrNoRows {
+				return
+			}
+
+			PanicIfError(utils.BuildErrorWithCaller(err))
+			found = true
+		}),
+		sqlQuery, args...,
+	)
+
+	return found
+}
+
+// Query for a row which must exist
+//
+// The callback should scan the row by RowExt.Scan(), which panics with sql.ErrNoRows if there is no row,
+// then the panic is replaced with a descriptive error carrying the SQL.
+func (dbController *DbController) MustQueryForRow(rowCallback RowCallback, sqlQuery string, args ...interface{}) {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	dbController.QueryForRow(
+		RowCallbackFunc(func(row *sql.Row) {
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+
+				if err := utils.SimpleErrorConverter(p); errors.Is(err, sql.ErrNoRows) {
+					PanicIfError(newSqlError(
+						"query", sqlQuery, args,
+						fmt.Errorf("The row must exist but no rows found: %w", sql.ErrNoRows),
+						or.GetCallerInfo(),
+					))
+				}
+
+				panic(p)
+			}()
+
+			rowCallback.ResultRow(row)
+		}),
+		sqlQuery, args...,
+	)
+}
+
+// Checks whether or not the query has any row
+//
+// The query should be as lightweight as possible, e.g.:
+//
+//	SELECT 1 FROM nqm_agent WHERE ag_hostname = ? LIMIT 1
+func (dbController *DbController) Exists(sqlQuery string, args ...interface{}) bool {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	numberOfRows := dbController.QueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			return IterateStop
+		}),
+		sqlQuery, args...,
+	)
+
+	return numberOfRows > 0
+}
+
+// Query for rows and gets the names of columns before iterating rows
+//
+// The "onColumns" is called exactly once before the first calling of NextRow(), even if there is no row.
+func (dbController *DbController) QueryForRowsWithHeader(
+	onColumns func(columns []string),
+	rowsCallback RowsCallback,
+	sqlQuery string, args ...interface{},
+) (numberOfRows uint) {
+	defer utils.DeferCatchPanicWithCaller()()
+	return dbController.queryForRowsContext(context.Background(), onColumns, rowsCallback, sqlQuery, args)
+}
+
+// Query for rows until the deadline is passed
+//
+// The deadline is checked between rows, once it is passed, the iteration is stopped and
+// the number of rows processed by the callback so far is returned.
+//
+// Unlike QueryForRowsContext(), passing the deadline is not an error.
+func (dbController *DbController) QueryForRowsWithDeadline(
+	deadline time.Time,
+	rowsCallback RowsCallback,
+	sqlQuery string, args ...interface{},
+) (numberOfRows uint) {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	dbController.QueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			if !time.Now().Before(deadline) {
+				return IterateStop
+			}
+
+			numberOfRows++
+			return rowsCallback.NextRow(rows)
+		}),
+		sqlQuery, args...,
+	)
+
+	return
+}
+
+// Queries for the total number of rows and a page of rows in a transaction
+//
+// The "countSQL" should return the total number of rows in the first column,
+// and the "rowsSQL" is appended with "LIMIT ? OFFSET ?". Both of the queries use the same args:
+//
+//	total, returned := dbController.QueryPage(
+//		"SELECT COUNT(*) FROM nqm_target WHERE tg_status = ?",
+//		"SELECT tg_id, tg_name FROM nqm_target WHERE tg_status = ? ORDER BY tg_id",
+//		20, 40, rowsCallback, true,
+//	)
+//
+// The panic is raised if limit or offset is negative, which could be captured by registered PanicHandlers.
+func (dbController *DbController) QueryPage(
+	countSQL, rowsSQL string, limit, offset int,
+	rowsCallback RowsCallback, args ...interface{},
+) (total uint, returned uint) {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	if limit < 0 || offset < 0 {
+		dbController.raiseToHandlers(
+			fmt.Errorf("Limit and offset must be non-negative. Limit: %d. Offset: %d", limit, offset),
+		)
+		return
+	}
+
+	pagedArgs := append(append([]interface{}{}, args...), limit, offset)
+	pagedSQL := rowsSQL + " LIMIT ? OFFSET ?"
+
+	dbController.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		txExt := ToTxExt(tx)
+
+		txExt.QueryForRow(
+			RowCallbackFunc(func(row *sql.Row) {
+				ToRowExt(row).Scan(&total)
+			}),
+			countSQL, args...,
+		)
+		returned = txExt.QueryForRows(rowsCallback, pagedSQL, pagedArgs...)
+
+		return TxCommit
+	}))
+
+	return
+}
+
+// Queries for rows page by page with keyset(the last key of previous page) instead of OFFSET
+//
+// The "buildSQL" gives the query of a page after the "lastKey"(nil for the first page),
+// the query should be ordered by the key and limited to "pageSize" rows:
+//
+//	dbController.QueryKeyset(
+//		100,
+//		func(lastKey interface{}) (string, []interface{}) {
+//			if lastKey == nil {
+//				return "SELECT tg_id, tg_name FROM nqm_target ORDER BY tg_id LIMIT ?", []interface{}{100}
+//			}
+//			return "SELECT tg_id, tg_name FROM nqm_target WHERE tg_id > ? ORDER BY tg_id LIMIT ?", []interface{}{lastKey, 100}
+//		},
+//		func(rows *RowsExt) interface{} {
+//			var id int32
+//			rows.Scan(&id, new(string))
+//			return id
+//		},
+//		process,
+//	)
+//
+// For every row, "process" is called and then "extractKey" gives the key of the row.
+// The querying is stopped while a page has fewer rows than "pageSize".
+//
+// The panic is raised if pageSize is not positive, which could be captured by registered PanicHandlers.
+func (dbController *DbController) QueryKeyset(
+	pageSize int,
+	buildSQL func(lastKey interface{}) (string, []interface{}),
+	extractKey func(*RowsExt) interface{},
+	process func(*RowsExt),
+) (totalProcessed uint) {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	if pageSize <= 0 {
+		dbController.raiseToHandlers(fmt.Errorf("Page size must be positive. Got: %d", pageSize))
+		return
+	}
+
+	var lastKey interface{}
+	for {
+		sqlQuery, args := buildSQL(lastKey)
+
+		numberOfRows := dbController.QueryForRows(
+			RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+				rowsExt := ToRowsExt(rows)
+
+				process(rowsExt)
+				lastKey = extractKey(rowsExt)
+
+				return IterateContinue
+			}),
+			sqlQuery, args...,
+		)
+
+		totalProcessed += numberOfRows
+		if numberOfRows < uint(pageSize) {
+			return
+		}
+	}
+}
+
+// Collects the results of scanning for every row
+//
+//	cars := dbController.Collect(
+//		func(rows *RowsExt) interface{} {
+//			car := &Car{}
+//			rows.Scan(&car.Id, &car.Name)
+//			return car
+//		},
+//		"SELECT c_id, c_name FROM car",
+//	)
+func (dbController *DbController) Collect(
+	scan func(*RowsExt) interface{},
+	sqlQuery string, args ...interface{},
+) []interface{} {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	result := make([]interface{}, 0)
+	dbController.QueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			result = append(result, scan(ToRowsExt(rows)))
+			return IterateContinue
+		}),
+		sqlQuery, args...,
+	)
+
+	return result
+}
@@ -0,0 +1,257 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	or "github.com/Cepave/open-falcon-backend/common/runtime"
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// Query for rows with cached result, which is keyed by the SQL and arguments
+//
+// Within the TTL, the rows are served from cache without hitting the database;
+// after the TTL is expired, the query is executed again and the cache is refreshed.
+// Only the successful result is cached.
+//
+// This method should be used for hot lookups of read-only data which changes rarely,
+// the *sql.Rows given to callback is replayed from memory, so it is safe to call it concurrently.
+// The concurrent callings of expired(or missed) key may hit the database more than once.
+//
+// The arguments are normalized by driver.DefaultParameterConverter(e.g. the value of pointer, driver.Valuer) for the key,
+// the query with argument which cannot be normalized is not cached.
+// At most 1024 results are cached, the ones which are going to be expired first are evicted.
+func (dbController *DbController) CachedQueryForRows(
+	ttl time.Duration,
+	rowsCallback RowsCallback,
+	sqlQuery string, args ...interface{},
+) (numberOfRows uint) {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	key, cacheable := buildQueryCacheKey(sqlQuery, args)
+
+	var dbFunc DbCallbackFunc = func(db *sql.DB) {
+		if !cacheable {
+			numberOfRows = replayCachedResult(dbController.loadCachedResult(db, sqlQuery, args), rowsCallback)
+			return
+		}
+
+		result := dbController.queryCache.get(key)
+		if result == nil {
+			result = dbController.loadCachedResult(db, sqlQuery, args)
+			dbController.queryCache.put(key, result, ttl)
+		}
+
+		numberOfRows = replayCachedResult(result, rowsCallback)
+	}
+
+	dbController.OperateOnDb(dbFunc)
+
+	return
+}
+
+// Builds the key of cache by the SQL and normalized values of arguments
+//
+// The "ok" would be false if any of the arguments cannot be normalized.
+func buildQueryCacheKey(sqlQuery string, args []interface{}) (key string, ok bool) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		value, err := driver.DefaultParameterConverter.ConvertValue(arg)
+		if err != nil {
+			return "", false
+		}
+
+		values[i] = value
+	}
+
+	return fmt.Sprintf("%s\x00%#v", sqlQuery, values), true
+}
+
+// Query for all of the rows, which are kept in memory
+func (dbController *DbController) loadCachedResult(db *sql.DB, sqlQuery string, args []interface{}) *cachedResult {
+	ctx, cancel := dbController.withDefaultTimeout(context.Background())
+	defer cancel()
+
+	rows, err := dbController.queryOnDb(ctx, db, sqlQuery, args)
+	if err != nil {
+		PanicIfError(newSqlError("query", sqlQuery, args, err, or.GetCallerInfo()))
+	}
+	defer rows.Close()
+
+	rowsExt := ToRowsExt(rows)
+	result := &cachedResult{columns: rowsExt.Columns()}
+	for rows.Next() {
+		result.values = append(result.values, rowsExt.scanValues())
+	}
+
+	PanicIfError(utils.BuildErrorWithCaller(rows.Err()))
+
+	return result
+}
+
+func replayCachedResult(result *cachedResult, rowsCallback RowsCallback) (numberOfRows uint) {
+	rows, err := replayDb.Query("", result)
+	PanicIfError(utils.BuildErrorWithCaller(err))
+	defer rows.Close()
+	defer trackColumnIndex(rows)()
+
+	for rows.Next() {
+		numberOfRows++
+
+		if rowsCallback.NextRow(rows) == IterateStop {
+			break
+		}
+	}
+
+	PanicIfError(utils.BuildErrorWithCaller(rows.Err()))
+
+	return
+}
+
+const defaultQueryCacheMaxEntries = 1024
+
+// The cache of query results with TTL
+type queryCache struct {
+	lock       sync.Mutex
+	entries    map[string]*queryCacheEntry
+	maxEntries int
+}
+
+type queryCacheEntry struct {
+	result   *cachedResult
+	expireAt time.Time
+}
+
+// The rows kept in memory
+type cachedResult struct {
+	columns []string
+	values  [][]interface{}
+}
+
+func newQueryCache() *queryCache {
+	return &queryCache{
+		entries:    make(map[string]*queryCacheEntry),
+		maxEntries: defaultQueryCacheMaxEntries,
+	}
+}
+
+// Gets the cached result, nil if it is missed or expired
+func (cache *queryCache) get(key string) *cachedResult {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	entry, ok := cache.entries[key]
+	if !ok {
+		return nil
+	}
+	if !time.Now().Before(entry.expireAt) {
+		delete(cache.entries, key)
+		return nil
+	}
+
+	return entry.result
+}
+
+// Puts the result into cache, the expired entries are removed as well
+//
+// If the cache is full, the entries which are going to be expired first are evicted.
+func (cache *queryCache) put(key string, result *cachedResult, ttl time.Duration) {
+	now := time.Now()
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	for k, entry := range cache.entries {
+		if !now.Before(entry.expireAt) {
+			delete(cache.entries, k)
+		}
+	}
+
+	if _, ok := cache.entries[key]; !ok {
+		for len(cache.entries) >= cache.maxEntries {
+			cache.evictFirstExpiring()
+		}
+	}
+
+	cache.entries[key] = &queryCacheEntry{result, now.Add(ttl)}
+}
+
+func (cache *queryCache) evictFirstExpiring() {
+	var evictedKey string
+	var evictedEntry *queryCacheEntry
+	for k, entry := range cache.entries {
+		if evictedEntry == nil || entry.expireAt.Before(evictedEntry.expireAt) {
+			evictedKey, evictedEntry = k, entry
+		}
+	}
+
+	delete(cache.entries, evictedKey)
+}
+
+// The database gives *sql.Rows from the *cachedResult passed as the argument of query
+var replayDb = sql.OpenDB(replayConnector{})
+
+type replayConnector struct{}
+
+func (replayConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return replayConn{}, nil
+}
+func (replayConnector) Driver() driver.Driver {
+	return replayDriver{}
+}
+
+type replayDriver struct{}
+
+func (replayDriver) Open(name string) (driver.Conn, error) {
+	return replayConn{}, nil
+}
+
+type replayConn struct{}
+
+func (replayConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("Prepare is not supported by replaying of cached rows")
+}
+func (replayConn) Close() error {
+	return nil
+}
+func (replayConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("Transaction is not supported by replaying of cached rows")
+}
+func (replayConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return &replayRows{result: args[0].Value.(*cachedResult)}, nil
+}
+
+// Accepts *cachedResult as the argument of query
+func (replayConn) CheckNamedValue(value *driver.NamedValue) error {
+	return nil
+}
+
+type replayRows struct {
+	result *cachedResult
+	index  int
+}
+
+func (rows *replayRows) Columns() []string {
+	return rows.result.columns
+}
+func (rows *replayRows) Close() error {
+	return nil
+}
+func (rows *replayRows) Next(dest []driver.Value) error {
+	if rows.index >= len(rows.result.values) {
+		return io.EOF
+	}
+
+	for i, value := range rows.result.values[rows.index] {
+		dest[i] = value
+	}
+	rows.index++
+
+	return nil
+}
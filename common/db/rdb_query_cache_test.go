@@ -0,0 +1,182 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbQueryCacheSuite struct{}
+
+var _ = Suite(&TestRdbQueryCacheSuite{})
+
+// Tests the database is hit once within TTL
+func (suite *TestRdbQueryCacheSuite) TestCachedQueryForRows(c *C) {
+	var hits int32
+	testedCtrl := newFakeDbController(&fakeDriverDb{
+		queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+			atomic.AddInt32(&hits, 1)
+			return &fakeRows{
+				columns: []string{"ag_id", "ag_name"},
+				values: [][]driver.Value{
+					{args[0].Value, []byte("agent-1")},
+					{int64(2), nil},
+				},
+			}, nil
+		},
+	})
+	defer testedCtrl.Release()
+
+	type sampleAgent struct {
+		id   int
+		name sql.NullString
+	}
+	queryAgents := func(status int) []sampleAgent {
+		var agents []sampleAgent
+		testedCtrl.CachedQueryForRows(
+			50*time.Millisecond,
+			RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+				var agent sampleAgent
+				ToRowsExt(rows).Scan(&agent.id, &agent.name)
+				agents = append(agents, agent)
+				return IterateContinue
+			}),
+			"SELECT ag_id, ag_name FROM nqm_agent WHERE ag_status = ?", status,
+		)
+		return agents
+	}
+	expectedAgents := []sampleAgent{
+		{1, sql.NullString{String: "agent-1", Valid: true}},
+		{2, sql.NullString{}},
+	}
+
+	/**
+	 * Concurrent queries within TTL
+	 */
+	c.Assert(queryAgents(1), DeepEquals, expectedAgents)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			queryAgents(1)
+		}()
+	}
+	wg.Wait()
+
+	c.Assert(queryAgents(1), DeepEquals, expectedAgents)
+	c.Assert(atomic.LoadInt32(&hits), Equals, int32(1))
+	// :~)
+
+	/**
+	 * Different arguments are cached separately
+	 */
+	queryAgents(2)
+	queryAgents(2)
+	c.Assert(atomic.LoadInt32(&hits), Equals, int32(2))
+	// :~)
+
+	/**
+	 * Refreshed after TTL is expired
+	 */
+	time.Sleep(60 * time.Millisecond)
+	c.Assert(queryAgents(1), DeepEquals, expectedAgents)
+	c.Assert(atomic.LoadInt32(&hits), Equals, int32(3))
+	// :~)
+}
+
+// Tests the failed query is not cached
+func (suite *TestRdbQueryCacheSuite) TestCachedQueryForRowsWithError(c *C) {
+	var hits int32
+	testedCtrl := newFakeDbController(&fakeDriverDb{
+		queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+			if atomic.AddInt32(&hits, 1) == 1 {
+				return nil, errors.New("sample failure")
+			}
+			return &fakeRows{columns: []string{"v"}, values: [][]driver.Value{{int64(1)}}}, nil
+		},
+	})
+	defer testedCtrl.Release()
+
+	queryValue := func() (numberOfRows uint) {
+		return testedCtrl.CachedQueryForRows(
+			time.Minute,
+			RowsCallbackFunc(func(rows *sql.Rows) IterateControl { return IterateContinue }),
+			"SELECT 1",
+		)
+	}
+
+	c.Assert(func() { queryValue() }, PanicMatches, ".*sample failure.*")
+	c.Assert(queryValue(), Equals, uint(1))
+	c.Assert(queryValue(), Equals, uint(1))
+	c.Assert(atomic.LoadInt32(&hits), Equals, int32(2))
+}
+
+// Tests the pointers of arguments are keyed by their values
+func (suite *TestRdbQueryCacheSuite) TestCachedQueryForRowsWithPointerArgs(c *C) {
+	var hits int32
+	testedCtrl := newFakeDbController(&fakeDriverDb{
+		queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+			atomic.AddInt32(&hits, 1)
+			return &fakeRows{columns: []string{"ag_id"}, values: [][]driver.Value{{args[0].Value}}}, nil
+		},
+	})
+	defer testedCtrl.Release()
+
+	queryId := func(arg interface{}) (id int64) {
+		testedCtrl.CachedQueryForRows(
+			time.Minute,
+			RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+				ToRowsExt(rows).Scan(&id)
+				return IterateContinue
+			}),
+			"SELECT ag_id FROM nqm_agent WHERE ag_id = ?", arg,
+		)
+		return
+	}
+
+	id := int64(1)
+	c.Assert(queryId(&id), Equals, int64(1))
+
+	/**
+	 * The same address with different value
+	 */
+	id = 2
+	c.Assert(queryId(&id), Equals, int64(2))
+	c.Assert(atomic.LoadInt32(&hits), Equals, int32(2))
+	// :~)
+
+	/**
+	 * Different addresses(or non-pointer) with the same value
+	 */
+	anotherId := int64(1)
+	c.Assert(queryId(&anotherId), Equals, int64(1))
+	c.Assert(queryId(int64(2)), Equals, int64(2))
+	c.Assert(queryId(sql.NullInt64{Int64: 2, Valid: true}), Equals, int64(2))
+	c.Assert(atomic.LoadInt32(&hits), Equals, int32(2))
+	// :~)
+}
+
+// Tests the number of cached results is bounded
+func (suite *TestRdbQueryCacheSuite) TestQueryCacheWithMaxEntries(c *C) {
+	testedCache := newQueryCache()
+	testedCache.maxEntries = 2
+
+	results := []*cachedResult{{}, {}, {}}
+	testedCache.put("k1", results[0], time.Minute)
+	testedCache.put("k2", results[1], 2*time.Minute)
+	testedCache.put("k1", results[0], 3*time.Minute)
+	c.Assert(testedCache.entries, HasLen, 2)
+
+	testedCache.put("k3", results[2], time.Minute)
+	c.Assert(testedCache.entries, HasLen, 2)
+	c.Assert(testedCache.get("k2"), IsNil)
+	c.Assert(testedCache.get("k1"), Equals, results[0])
+	c.Assert(testedCache.get("k3"), Equals, results[2])
+}
@@ -0,0 +1,327 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbQuerySuite struct{}
+
+var _ = Suite(&TestRdbQuerySuite{})
+
+// Tests the query for single value
+func (suite *TestRdbQuerySuite) TestQueryForScalar(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec("CREATE TABLE test_scalar(ts_id INT PRIMARY KEY, ts_value INT)")
+	testedCtrl.Exec("INSERT INTO test_scalar VALUES(1, 30), (2, NULL)")
+
+	/**
+	 * Existing value
+	 */
+	var count int
+	c.Assert(testedCtrl.QueryForScalar(&count, "SELECT COUNT(*) FROM test_scalar"), Equals, true)
+	c.Assert(count, Equals, 2)
+	// :~)
+
+	/**
+	 * NULL value
+	 */
+	nullValue := sql.NullInt64{Int64: 99, Valid: true}
+	c.Assert(testedCtrl.QueryForScalar(&nullValue, "SELECT ts_value FROM test_scalar WHERE ts_id = ?", 2), Equals, true)
+	c.Assert(nullValue.Valid, Equals, false)
+	// :~)
+
+	/**
+	 * Empty result
+	 */
+	value := -1
+	c.Assert(testedCtrl.QueryForScalar(&value, "SELECT ts_value FROM test_scalar WHERE ts_id = ?", 3), Equals, false)
+	c.Assert(value, Equals, -1)
+	// :~)
+
+	/**
+	 * Error of scanning
+	 */
+	c.Assert(
+		func() { testedCtrl.QueryForScalar(&value, "SELECT ts_value FROM test_scalar WHERE ts_id = ?", 2) },
+		PanicMatches, ".*converting NULL.*",
+	)
+	// :~)
+}
+
+// Tests the checking of existence
+func (suite *TestRdbQuerySuite) TestExists(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec("CREATE TABLE test_exists(te_id INT PRIMARY KEY)")
+	testedCtrl.Exec("INSERT INTO test_exists VALUES(1), (2)")
+
+	c.Assert(testedCtrl.Exists("SELECT 1 FROM test_exists WHERE te_id = ? LIMIT 1", 2), Equals, true)
+	c.Assert(testedCtrl.Exists("SELECT 1 FROM test_exists WHERE te_id = ? LIMIT 1", 3), Equals, false)
+}
+
+// Tests the query for a row which must exist
+func (suite *TestRdbQuerySuite) TestMustQueryForRow(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.ExecQueriesInTx(
+		"CREATE TABLE test_must(tm_key VARCHAR(32) PRIMARY KEY, tm_value VARCHAR(32))",
+		"INSERT INTO test_must VALUES('k1', 'v1')",
+	)
+
+	var testedValue string
+	scanValue := RowCallbackFunc(func(row *sql.Row) {
+		ToRowExt(row).Scan(&testedValue)
+	})
+
+	testedCtrl.MustQueryForRow(scanValue, "SELECT tm_value FROM test_must WHERE tm_key = ?", "k1")
+	c.Assert(testedValue, Equals, "v1")
+
+	c.Assert(
+		func() {
+			testedCtrl.MustQueryForRow(scanValue, "SELECT tm_value FROM test_must WHERE tm_key = ?", "k2")
+		},
+		PanicMatches, `.*The row must exist but no rows found.*SQL: "SELECT tm_value FROM test_must WHERE tm_key = \?".*"k2".*`,
+	)
+}
+
+// Tests the querying for a page of rows
+func (suite *TestRdbQuerySuite) TestQueryPage(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.ExecQueriesInTx(
+		"CREATE TABLE test_page(tp_id INT PRIMARY KEY, tp_status BOOLEAN)",
+		"INSERT INTO test_page VALUES(1, 1), (2, 1), (3, 0), (4, 1), (5, 1), (6, 1)",
+	)
+
+	testCases := []*struct {
+		limit, offset    int
+		expectedReturned uint
+		expectedIds      []int
+	}{
+		{2, 0, 2, []int{1, 2}},
+		{2, 3, 2, []int{5, 6}},
+		{10, 4, 1, []int{6}},
+		{2, 10, 0, nil},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		var testedIds []int
+		total, returned := testedCtrl.QueryPage(
+			"SELECT COUNT(*) FROM test_page WHERE tp_status = ?",
+			"SELECT tp_id FROM test_page WHERE tp_status = ? ORDER BY tp_id",
+			testCase.limit, testCase.offset,
+			RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+				var id int
+				ToRowsExt(rows).Scan(&id)
+				testedIds = append(testedIds, id)
+				return IterateContinue
+			}),
+			true,
+		)
+
+		c.Assert(total, Equals, uint(5), comment)
+		c.Assert(returned, Equals, testCase.expectedReturned, comment)
+		c.Assert(testedIds, DeepEquals, testCase.expectedIds, comment)
+	}
+
+	c.Assert(
+		func() {
+			testedCtrl.QueryPage(
+				"SELECT COUNT(*) FROM test_page", "SELECT tp_id FROM test_page",
+				-1, 0, RowsCallbackFunc(func(rows *sql.Rows) IterateControl { return IterateContinue }),
+			)
+		},
+		PanicMatches, ".*non-negative.*",
+	)
+
+	/**
+	 * The errors are captured by handlers
+	 */
+	var err error
+	capturingCtrl := *testedCtrl
+	capturingCtrl.panicHandlers = nil
+	capturingCtrl.RegisterPanicHandler(NewDbErrorCapture(&err))
+
+	noopCallback := RowsCallbackFunc(func(rows *sql.Rows) IterateControl { return IterateContinue })
+
+	capturingCtrl.QueryPage("SELECT COUNT(*) FROM test_page", "SELECT tp_id FROM test_page", 0, -1, noopCallback)
+	c.Assert(err, ErrorMatches, "(?s).*rdb_query.go:[0-9]+:.*non-negative.*")
+
+	err = nil
+	capturingCtrl.QueryPage("SELECT COUNT(*) FROM test_page", "SELECT no_such_column FROM test_page", 2, 0, noopCallback)
+
+	var dbErr *DbError
+	c.Assert(errors.As(err, &dbErr), Equals, true)
+	c.Assert(dbErr.Op, Equals, "query")
+	c.Assert(dbErr.SQL, Equals, "SELECT no_such_column FROM test_page LIMIT ? OFFSET ?")
+	// :~)
+}
+
+// Tests the collecting of rows
+func (suite *TestRdbQuerySuite) TestCollect(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.ExecQueriesInTx(
+		"CREATE TABLE test_collect(tc_id INT PRIMARY KEY, tc_name VARCHAR(32))",
+		"INSERT INTO test_collect VALUES(1, 'c1'), (2, 'c2'), (3, 'c3')",
+	)
+
+	type collectedCar struct {
+		id   int
+		name string
+	}
+
+	testedResult := testedCtrl.Collect(
+		func(rows *RowsExt) interface{} {
+			car := &collectedCar{}
+			rows.Scan(&car.id, &car.name)
+			return car
+		},
+		"SELECT tc_id, tc_name FROM test_collect WHERE tc_id > ? ORDER BY tc_id", 0,
+	)
+
+	c.Assert(testedResult, DeepEquals, []interface{}{
+		&collectedCar{1, "c1"}, &collectedCar{2, "c2"}, &collectedCar{3, "c3"},
+	})
+}
+
+// Tests the columns given before iterating rows
+func (suite *TestRdbQuerySuite) TestQueryForRowsWithHeader(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.ExecQueriesInTx(
+		"CREATE TABLE test_header(th_id INT PRIMARY KEY, th_name VARCHAR(32))",
+		"INSERT INTO test_header VALUES(1, 'h1'), (2, 'h2')",
+	)
+
+	testCases := []*struct {
+		sampleId     int
+		expectedRows uint
+	}{
+		{0, 2},
+		{2, 0},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		var calledColumns [][]string
+		columnsBeforeRow := true
+		testedRows := testedCtrl.QueryForRowsWithHeader(
+			func(columns []string) {
+				calledColumns = append(calledColumns, columns)
+			},
+			RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+				columnsBeforeRow = columnsBeforeRow && len(calledColumns) == 1
+				return IterateContinue
+			}),
+			"SELECT th_id, th_name FROM test_header WHERE th_id > ?", testCase.sampleId,
+		)
+
+		c.Assert(testedRows, Equals, testCase.expectedRows, comment)
+		c.Assert(calledColumns, DeepEquals, [][]string{{"th_id", "th_name"}}, comment)
+		c.Assert(columnsBeforeRow, Equals, true, comment)
+	}
+}
+
+// Tests the stopping of iteration after deadline
+func (suite *TestRdbQuerySuite) TestQueryForRowsWithDeadline(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.ExecQueriesInTx(
+		"CREATE TABLE test_deadline(td_id INT PRIMARY KEY)",
+		"INSERT INTO test_deadline VALUES(1), (2), (3), (4), (5), (6), (7), (8)",
+	)
+
+	slowCallback := RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+		time.Sleep(20 * time.Millisecond)
+		return IterateContinue
+	})
+
+	testedRows := testedCtrl.QueryForRowsWithDeadline(
+		time.Now().Add(50*time.Millisecond), slowCallback,
+		"SELECT td_id FROM test_deadline",
+	)
+	c.Assert(testedRows > 0 && testedRows < 8, Equals, true, Commentf("Number of rows: %d", testedRows))
+
+	testedRows = testedCtrl.QueryForRowsWithDeadline(
+		time.Now().Add(time.Minute), slowCallback,
+		"SELECT td_id FROM test_deadline WHERE td_id <= ?", 2,
+	)
+	c.Assert(testedRows, Equals, uint(2))
+}
+
+// Tests the walking of table by keyset
+func (suite *TestRdbQuerySuite) TestQueryKeyset(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec("CREATE TABLE test_keyset(tk_id INT PRIMARY KEY)")
+	rows := make([][]interface{}, 250)
+	for i := range rows {
+		rows[i] = []interface{}{i + 1}
+	}
+	testedCtrl.BulkInsert("test_keyset", []string{"tk_id"}, rows)
+
+	var lastKeys []interface{}
+	var processedIds []int
+	totalProcessed := testedCtrl.QueryKeyset(
+		100,
+		func(lastKey interface{}) (string, []interface{}) {
+			lastKeys = append(lastKeys, lastKey)
+
+			if lastKey == nil {
+				return "SELECT tk_id FROM test_keyset ORDER BY tk_id LIMIT ?", []interface{}{100}
+			}
+			return "SELECT tk_id FROM test_keyset WHERE tk_id > ? ORDER BY tk_id LIMIT ?", []interface{}{lastKey, 100}
+		},
+		func(rows *RowsExt) interface{} {
+			var id int
+			rows.Scan(&id)
+			return id
+		},
+		func(rows *RowsExt) {
+			var id int
+			rows.Scan(&id)
+			processedIds = append(processedIds, id)
+		},
+	)
+
+	c.Assert(totalProcessed, Equals, uint(250))
+	c.Assert(lastKeys, DeepEquals, []interface{}{nil, 100, 200})
+	c.Assert(processedIds, HasLen, 250)
+	for i, id := range processedIds {
+		c.Assert(id, Equals, i+1)
+	}
+
+	c.Assert(
+		func() { testedCtrl.QueryKeyset(0, nil, nil, nil) },
+		PanicMatches, ".*Page size must be positive.*",
+	)
+
+	/**
+	 * The error is captured by handler
+	 */
+	var err error
+	capturingCtrl := *testedCtrl
+	capturingCtrl.panicHandlers = nil
+	capturingCtrl.RegisterPanicHandler(NewDbErrorCapture(&err))
+
+	c.Assert(capturingCtrl.QueryKeyset(-1, nil, nil, nil), Equals, uint(0))
+	c.Assert(err, ErrorMatches, "(?s).*rdb_query.go:[0-9]+:.*Page size must be positive.*")
+	// :~)
+}
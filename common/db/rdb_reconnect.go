@@ -0,0 +1,36 @@
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+)
+
+// Sets whether or not to retry the query once if it has failed by broken connection
+//
+// The broken connection is recognized by driver.ErrBadConn, or "server has gone away"(2006) and
+// "lost connection"(2013) of MySQL. The retried query uses the connection re-established by the pool.
+//
+// This mode applies to executing of statement and query for rows or a row, other errors are not retried.
+//
+// If the validation query is set(see SetValidationQuery()), the query is executed before the retrying,
+// the retrying is skipped if the validation has failed.
+func (dbController *DbController) SetReconnectOnError(enabled bool) {
+	dbController.reconnectOnError = enabled
+}
+
+func (dbController *DbController) retryOnConnectionError(f func() error) error {
+	err := f()
+	if err == nil || !dbController.reconnectOnError || !isConnectionError(err) {
+		return err
+	}
+
+	if dbController.validationQuery != "" && dbController.Ping() != nil {
+		return err
+	}
+
+	return f()
+}
+
+func isConnectionError(err error) bool {
+	return errors.Is(err, driver.ErrBadConn) || isMySQLConnectionError(err)
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"sync/atomic"
+
+	"github.com/go-sql-driver/mysql"
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbReconnectSuite struct{}
+
+var _ = Suite(&TestRdbReconnectSuite{})
+
+// Tests the retrying of query after broken connection
+func (suite *TestRdbReconnectSuite) TestReconnectOnError(c *C) {
+	testCases := []*struct {
+		err               error
+		numberOfFailures  int32
+		reconnect         bool
+		expectedSuccess   bool
+		expectedExecuting int32
+	}{
+		// database/sql retries driver.ErrBadConn by itself(3 attempts in total)
+		{driver.ErrBadConn, 3, true, true, 4},
+		{driver.ErrBadConn, 3, false, false, 3},
+		{&mysql.MySQLError{Number: 2006, Message: "MySQL server has gone away"}, 1, true, true, 2},
+		{&mysql.MySQLError{Number: 2013, Message: "Lost connection to MySQL server"}, 1, true, true, 2},
+		{&mysql.MySQLError{Number: 2013, Message: "Lost connection to MySQL server"}, 1, false, false, 1},
+		{mysql.ErrInvalidConn, 1, true, true, 2},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, 1, true, false, 1},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		/**
+		 * Fails for first N times of executing, then succeeds
+		 */
+		var executing int32
+		failOrNot := func() error {
+			if atomic.AddInt32(&executing, 1) <= testCase.numberOfFailures {
+				return testCase.err
+			}
+			return nil
+		}
+		// :~)
+
+		testedCtrl := newFakeDbController(&fakeDriverDb{
+			execFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Result, error) {
+				if err := failOrNot(); err != nil {
+					return nil, err
+				}
+				return driver.RowsAffected(1), nil
+			},
+			queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+				if err := failOrNot(); err != nil {
+					return nil, err
+				}
+				return &fakeRows{columns: []string{"v"}, values: [][]driver.Value{{int64(1)}}}, nil
+			},
+		})
+		testedCtrl.SetReconnectOnError(testCase.reconnect)
+
+		testedFuncs := []func(){
+			func() { testedCtrl.Exec("UPDATE nqm_agent SET ag_status = 1") },
+			func() {
+				testedCtrl.QueryForRows(
+					RowsCallbackFunc(func(rows *sql.Rows) IterateControl { return IterateContinue }),
+					"SELECT 1",
+				)
+			},
+			func() {
+				testedCtrl.QueryForRow(
+					RowCallbackFunc(func(row *sql.Row) {
+						var v int
+						ToRowExt(row).Scan(&v)
+					}),
+					"SELECT 1",
+				)
+			},
+		}
+
+		for _, testedFunc := range testedFuncs {
+			atomic.StoreInt32(&executing, 0)
+
+			if testCase.expectedSuccess {
+				testedFunc()
+			} else {
+				c.Assert(testedFunc, PanicMatches, ".*"+regexp.QuoteMeta(testCase.err.Error())+".*", comment)
+			}
+
+			c.Assert(atomic.LoadInt32(&executing), Equals, testCase.expectedExecuting, comment)
+		}
+
+		testedCtrl.Release()
+	}
+}
+
+// Tests the validation query executed before the retrying
+func (suite *TestRdbReconnectSuite) TestReconnectWithValidationQuery(c *C) {
+	testCases := []*struct {
+		validationErr    error
+		expectedSuccess  bool
+		expectedExecuted []string
+	}{
+		{nil, true, []string{"UPDATE nqm_agent SET ag_status = 1", "SELECT 1", "UPDATE nqm_agent SET ag_status = 1"}},
+		{errors.New("proxy is not ready"), false, []string{"UPDATE nqm_agent SET ag_status = 1", "SELECT 1"}},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		var executing int32
+		fakeDb := &fakeDriverDb{
+			execFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Result, error) {
+				if atomic.AddInt32(&executing, 1) == 1 {
+					return nil, &mysql.MySQLError{Number: 2006, Message: "MySQL server has gone away"}
+				}
+				return driver.RowsAffected(1), nil
+			},
+			queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+				if testCase.validationErr != nil {
+					return nil, testCase.validationErr
+				}
+				return &fakeRows{columns: []string{"1"}, values: [][]driver.Value{{int64(1)}}}, nil
+			},
+		}
+		testedCtrl := newFakeDbController(fakeDb)
+		testedCtrl.SetReconnectOnError(true)
+		testedCtrl.SetValidationQuery("SELECT 1")
+
+		testedFunc := func() { testedCtrl.Exec("UPDATE nqm_agent SET ag_status = 1") }
+		if testCase.expectedSuccess {
+			testedFunc()
+		} else {
+			c.Assert(testedFunc, PanicMatches, ".*server has gone away.*", comment)
+		}
+
+		c.Assert(fakeDb.executed(), DeepEquals, testCase.expectedExecuted, comment)
+
+		testedCtrl.Release()
+	}
+}
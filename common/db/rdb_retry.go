@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	or "github.com/Cepave/open-falcon-backend/common/runtime"
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// The predicate used by InTxWithRetry() to decide whether or not a failed transaction should be retried
+//
+// By default, the deadlock(1213) and lock wait timeout(1205) of MySQL are retryable.
+var IsRetryableError func(error) bool = isMySQLRetryableError
+
+// The delay before the first retry, the delay is doubled for every following retry
+var txRetryBaseDelay = 50 * time.Millisecond
+
+// Executes in transaction, the transaction would be retried if the raised panic is retryable(by IsRetryableError).
+//
+// Every failed attempt is rollbacked before the next attempt, which is delayed by exponential backoff.
+//
+// The non-retryable error or the error of the last attempt would be re-paniced.
+func (dbController *DbController) InTxWithRetry(maxAttempts int, txCallback TxCallback) {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	txFunc := dbController.buildTxFunc(context.Background(), nil, or.GetCallerInfo(), txCallback)
+
+	var dbFunc DbCallbackFunc = func(db *sql.DB) {
+		for attempt := 1; ; attempt++ {
+			err := tryDbFunc(txFunc, db)
+			if err == nil {
+				return
+			}
+
+			if attempt >= maxAttempts || !IsRetryableError(err) {
+				panic(err)
+			}
+
+			time.Sleep(txRetryBaseDelay << uint(attempt-1))
+		}
+	}
+
+	dbController.OperateOnDb(dbFunc)
+}
+
+func tryDbFunc(dbFunc DbCallbackFunc, db *sql.DB) (err error) {
+	defer func() {
+		p := recover()
+		if p != nil {
+			err = utils.SimpleErrorConverter(p)
+		}
+	}()
+
+	dbFunc(db)
+	return
+}
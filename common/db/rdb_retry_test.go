@@ -0,0 +1,70 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbRetrySuite struct{}
+
+var _ = Suite(&TestRdbRetrySuite{})
+
+// Tests the retrying of transaction with deadlock error
+func (suite *TestRdbRetrySuite) TestInTxWithRetry(c *C) {
+	defer func(oldDelay time.Duration) { txRetryBaseDelay = oldDelay }(txRetryBaseDelay)
+	txRetryBaseDelay = time.Millisecond
+
+	testCases := []*struct {
+		errors           []error
+		maxAttempts      int
+		expectedAttempts int
+		// Empty if there is no panic expected
+		expectedPanic string
+	}{
+		{[]error{&mysql.MySQLError{Number: 1213, Message: "Deadlock found"}}, 3, 2, ""},
+		{[]error{&mysql.MySQLError{Number: 1205}, &mysql.MySQLError{Number: 1213}}, 3, 3, ""},
+		{
+			[]error{&mysql.MySQLError{Number: 1213, Message: "Deadlock found"}, &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}},
+			2, 2, ".*Error 1213: Deadlock found.*",
+		},
+		{[]error{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}}, 3, 1, ".*Error 1062: Duplicate entry.*"},
+		{[]error{fmt.Errorf("Other error")}, 3, 1, ".*Other error.*"},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		attempts := 0
+		testedCtrl := newFakeDbController(&fakeDriverDb{
+			execFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Result, error) {
+				attempts++
+				if attempts <= len(testCase.errors) {
+					return nil, testCase.errors[attempts-1]
+				}
+
+				return driver.RowsAffected(1), nil
+			},
+		})
+
+		testedFunc := func() {
+			testedCtrl.InTxWithRetry(testCase.maxAttempts, TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+				ToTxExt(tx).Exec("UPDATE nqm_agent SET ag_status = 1")
+				return TxCommit
+			}))
+		}
+
+		if testCase.expectedPanic != "" {
+			c.Assert(testedFunc, PanicMatches, testCase.expectedPanic, comment)
+		} else {
+			testedFunc()
+		}
+		c.Assert(attempts, Equals, testCase.expectedAttempts, comment)
+
+		testedCtrl.Release()
+	}
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// Controller of database with separated pools for reading and writing
+//
+// The queries(QueryForRows, QueryForRow) are routed to the read pool(e.g. replicas of MySQL),
+// the executing(Exec) and transactions(InTx) are routed to the write pool(e.g. primary of MySQL).
+//
+// The transactions always use the write pool, even for the SELECTs in them.
+type RWDbController struct {
+	read, write *DbController
+}
+
+// Opens the databases of read and write pools
+func NewRWDbController(readCfg, writeCfg *DbConfig) (*RWDbController, error) {
+	write, err := writeCfg.ToDbController()
+	if err != nil {
+		return nil, fmt.Errorf("Write pool has error: %v", err)
+	}
+
+	read, err := readCfg.ToDbController()
+	if err != nil {
+		write.Release()
+		return nil, fmt.Errorf("Read pool has error: %v", err)
+	}
+
+	return &RWDbController{read: read, write: write}, nil
+}
+
+// Gets the controller of read pool
+func (rwController *RWDbController) Read() *DbController {
+	return rwController.read
+}
+
+// Gets the controller of write pool
+func (rwController *RWDbController) Write() *DbController {
+	return rwController.write
+}
+
+// Query for rows on the read pool
+func (rwController *RWDbController) QueryForRows(
+	rowsCallback RowsCallback,
+	sqlQuery string, args ...interface{},
+) uint {
+	defer utils.DeferCatchPanicWithCaller()()
+	return rwController.read.QueryForRows(rowsCallback, sqlQuery, args...)
+}
+
+// Query for a row on the read pool
+func (rwController *RWDbController) QueryForRow(
+	rowCallback RowCallback,
+	sqlQuery string, args ...interface{},
+) {
+	defer utils.DeferCatchPanicWithCaller()()
+	rwController.read.QueryForRow(rowCallback, sqlQuery, args...)
+}
+
+// Executes the query on the write pool
+func (rwController *RWDbController) Exec(query string, args ...interface{}) sql.Result {
+	defer utils.DeferCatchPanicWithCaller()()
+	return rwController.write.Exec(query, args...)
+}
+
+// Executes in transaction on the write pool
+func (rwController *RWDbController) InTx(txCallback TxCallback) {
+	defer utils.DeferCatchPanicWithCaller()()
+	rwController.write.InTx(txCallback)
+}
+
+// Releases both of the read and write pools
+func (rwController *RWDbController) Release() {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	defer rwController.write.Release()
+	rwController.read.Release()
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"database/sql"
+	"sync"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbRwSuite struct{}
+
+var _ = Suite(&TestRdbRwSuite{})
+
+var (
+	fakeReadDb         = &fakeDriverDb{}
+	fakeWriteDb        = &fakeDriverDb{}
+	registerFakeRwOnce sync.Once
+)
+
+func buildFakeRwDbController(c *C) *RWDbController {
+	registerFakeRwOnce.Do(func() {
+		sql.Register("fake-read", fakeReadDb.Driver())
+		sql.Register("fake-write", fakeWriteDb.Driver())
+	})
+
+	testedCtrl, err := NewRWDbController(
+		&DbConfig{Driver: "fake-read"},
+		&DbConfig{Driver: "fake-write"},
+	)
+	c.Assert(err, IsNil)
+
+	return testedCtrl
+}
+
+// Tests the routing of queries to read or write pool
+func (suite *TestRdbRwSuite) TestRouting(c *C) {
+	testedCtrl := buildFakeRwDbController(c)
+	defer testedCtrl.Release()
+
+	numberOfRead := len(fakeReadDb.executed())
+	numberOfWrite := len(fakeWriteDb.executed())
+
+	testedCtrl.QueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl { return IterateContinue }),
+		"SELECT 1 FROM read_rows",
+	)
+	testedCtrl.QueryForRow(
+		RowCallbackFunc(func(row *sql.Row) {}),
+		"SELECT 1 FROM read_row",
+	)
+	testedCtrl.Exec("UPDATE write_exec SET a = 1")
+	testedCtrl.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		rows, err := tx.Query("SELECT 1 FROM write_tx")
+		c.Assert(err, IsNil)
+		rows.Close()
+
+		return TxCommit
+	}))
+
+	c.Assert(fakeReadDb.executed()[numberOfRead:], DeepEquals, []string{
+		"SELECT 1 FROM read_rows", "SELECT 1 FROM read_row",
+	})
+	c.Assert(fakeWriteDb.executed()[numberOfWrite:], DeepEquals, []string{
+		"UPDATE write_exec SET a = 1", "SELECT 1 FROM write_tx",
+	})
+}
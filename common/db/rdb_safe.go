@@ -0,0 +1,75 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// The methods in this file return error instead of panic,
+// which may be used by code on boundary of packages.
+//
+// The registered PanicHandlers are not called by these methods.
+
+// Executes the query string and returns the error if there is any
+func (dbController *DbController) ExecE(query string, args ...interface{}) (result sql.Result, err error) {
+	dbController.safeCall(&err, func(ctrl *DbController) {
+		result = ctrl.Exec(query, args...)
+	})
+	return
+}
+
+// Query for rows and returns the error if there is any
+func (dbController *DbController) QueryForRowsE(
+	rowsCallback RowsCallback,
+	sqlQuery string, args ...interface{},
+) (numberOfRows uint, err error) {
+	dbController.safeCall(&err, func(ctrl *DbController) {
+		numberOfRows = ctrl.QueryForRows(rowsCallback, sqlQuery, args...)
+	})
+	return
+}
+
+// Query for a row and returns the error if there is any
+func (dbController *DbController) QueryForRowE(
+	rowCallback RowCallback,
+	sqlQuery string, args ...interface{},
+) (err error) {
+	dbController.safeCall(&err, func(ctrl *DbController) {
+		ctrl.QueryForRow(rowCallback, sqlQuery, args...)
+	})
+	return
+}
+
+// Executes in transaction and returns the error if there is any
+//
+// If the callback is TxCallbackE and it returns an error, the transaction is rollbacked and
+// the error is returned as it is(without the information of caller).
+func (dbController *DbController) InTxE(txCallback TxCallback) (err error) {
+	dbController.safeCall(&err, func(ctrl *DbController) {
+		ctrl.InTx(txCallback)
+	})
+
+	var callbackError *txCallbackError
+	if errors.As(err, &callbackError) {
+		err = callbackError.err
+	}
+	return
+}
+
+// Calls the function with a controller which has only the capture of error as PanicHandler
+func (dbController *DbController) safeCall(errHolder *error, targetFunc func(ctrl *DbController)) {
+	capturingCtrl := *dbController
+	capturingCtrl.panicHandlers = nil
+	capturingCtrl.RegisterPanicHandler(NewDbErrorCapture(errHolder))
+
+	defer func() {
+		p := recover()
+		if p != nil && *errHolder == nil {
+			*errHolder = utils.SimpleErrorConverter(p)
+		}
+	}()
+
+	targetFunc(&capturingCtrl)
+}
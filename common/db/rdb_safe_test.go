@@ -0,0 +1,159 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbSafeSuite struct{}
+
+var _ = Suite(&TestRdbSafeSuite{})
+
+// Tests the methods with returned error
+func (suite *TestRdbSafeSuite) TestMethodsWithError(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	numberOfHandled := 0
+	testedCtrl.RegisterPanicHandler(func(p interface{}) {
+		numberOfHandled++
+	})
+
+	/**
+	 * Failed methods
+	 */
+	_, err := testedCtrl.ExecE("No Such SQL Stmt")
+	c.Assert(err, ErrorMatches, ".*syntax error.*")
+
+	_, err = testedCtrl.QueryForRowsE(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl { return IterateContinue }),
+		"SELECT * FROM no_such_table",
+	)
+	c.Assert(err, ErrorMatches, ".*no such table.*")
+
+	err = testedCtrl.QueryForRowE(
+		RowCallbackFunc(func(row *sql.Row) { ToRowExt(row).Scan(new(int)) }),
+		"SELECT * FROM no_such_table",
+	)
+	c.Assert(err, ErrorMatches, ".*no such table.*")
+
+	err = testedCtrl.InTxE(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		ToTxExt(tx).Exec("INSERT INTO no_such_table VALUES(1)")
+		return TxCommit
+	}))
+	c.Assert(err, ErrorMatches, ".*no such table.*")
+	// :~)
+
+	/**
+	 * Successful method
+	 */
+	result, err := testedCtrl.ExecE("CREATE TABLE test_safe_1(ts_id INT)")
+	c.Assert(err, IsNil)
+	c.Assert(result, NotNil)
+	// :~)
+
+	/**
+	 * The permanent handler is still working
+	 */
+	c.Assert(numberOfHandled, Equals, 0)
+	testedCtrl.Exec("No Such SQL Stmt")
+	c.Assert(numberOfHandled, Equals, 1)
+	// :~)
+}
+
+var errSampleQuota = errors.New("quota is exceeded")
+
+// Tests the rollback with error returned by TxCallbackE
+func (suite *TestRdbSafeSuite) TestInTxEWithCallbackError(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec("CREATE TABLE test_safe_2(ts_id INT)")
+
+	err := testedCtrl.InTxE(TxCallbackE(func(tx *sql.Tx) (TxFinale, error) {
+		ToTxExt(tx).Exec("INSERT INTO test_safe_2 VALUES(1)")
+		return TxCommit, errSampleQuota
+	}))
+	c.Assert(err, Equals, errSampleQuota)
+
+	var count int
+	testedCtrl.QueryForRow(
+		RowCallbackFunc(func(row *sql.Row) { ToRowExt(row).Scan(&count) }),
+		"SELECT COUNT(*) FROM test_safe_2",
+	)
+	c.Assert(count, Equals, 0)
+
+	/**
+	 * Without error, the finale is respected
+	 */
+	err = testedCtrl.InTxE(TxCallbackE(func(tx *sql.Tx) (TxFinale, error) {
+		ToTxExt(tx).Exec("INSERT INTO test_safe_2 VALUES(2)")
+		return TxCommit, nil
+	}))
+	c.Assert(err, IsNil)
+
+	testedCtrl.QueryForRow(
+		RowCallbackFunc(func(row *sql.Row) { ToRowExt(row).Scan(&count) }),
+		"SELECT COUNT(*) FROM test_safe_2",
+	)
+	c.Assert(count, Equals, 1)
+	// :~)
+}
+
+// Tests the commit, rollback and abort of transaction
+func (suite *TestRdbSafeSuite) TestInTxEWithAbort(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec("CREATE TABLE test_safe_3(ts_id INT)")
+
+	testCases := []*struct {
+		finale        TxFinale
+		err           error
+		expectedErr   error
+		expectedCount int
+	}{
+		{TxCommit, nil, nil, 1},
+		{TxRollback, nil, nil, 0},
+		{TxAbort, errSampleQuota, errSampleQuota, 0},
+		{TxAbort, nil, ErrTxAborted, 0},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		testedCtrl.Exec("DELETE FROM test_safe_3")
+
+		err := testedCtrl.InTxE(TxCallbackE(func(tx *sql.Tx) (TxFinale, error) {
+			ToTxExt(tx).Exec("INSERT INTO test_safe_3 VALUES(1)")
+			return testCase.finale, testCase.err
+		}))
+		if testCase.expectedErr == nil {
+			c.Assert(err, IsNil, comment)
+		} else {
+			c.Assert(errors.Is(err, testCase.expectedErr), Equals, true, comment)
+		}
+
+		var count int
+		testedCtrl.QueryForRow(
+			RowCallbackFunc(func(row *sql.Row) { ToRowExt(row).Scan(&count) }),
+			"SELECT COUNT(*) FROM test_safe_3",
+		)
+		c.Assert(count, Equals, testCase.expectedCount, comment)
+	}
+
+	/**
+	 * The attached error is raised by InTx()
+	 */
+	c.Assert(
+		func() {
+			testedCtrl.InTx(TxCallbackE(func(tx *sql.Tx) (TxFinale, error) {
+				return TxAbort, errSampleQuota
+			}))
+		},
+		PanicMatches, ".*quota is exceeded.*",
+	)
+	// :~)
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// Tracks the in-flight operations of controller
+type dbLifecycle struct {
+	lock     sync.RWMutex
+	closing  bool
+	inFlight sync.WaitGroup
+}
+
+// Stops accepting new operations and closes the database after the in-flight operations are finished
+//
+// The new operations after calling of this method would raise panic of "closing" error,
+// which could be captured by registered PanicHandlers.
+//
+// If the context is done before the in-flight operations are finished,
+// the database is closed anyway and the error of context is returned.
+func (dbController *DbController) Shutdown(ctx context.Context) error {
+	dbController.needInitializedOrPanic()
+
+	lifecycle := dbController.lifecycle
+	lifecycle.lock.Lock()
+	lifecycle.closing = true
+	lifecycle.lock.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		lifecycle.inFlight.Wait()
+		close(drained)
+	}()
+
+	var ctxErr error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		ctxErr = ctx.Err()
+	}
+
+	if dbController.stmtCache != nil {
+		dbController.stmtCache.clear()
+	}
+	if err := dbController.dbObject.Close(); err != nil {
+		return fmt.Errorf("Release database connection error. %v", err)
+	}
+
+	return ctxErr
+}
+
+func (dbController *DbController) beginOperation() {
+	lifecycle := dbController.lifecycle
+	if lifecycle == nil {
+		return
+	}
+
+	lifecycle.lock.RLock()
+	defer lifecycle.lock.RUnlock()
+
+	if lifecycle.closing {
+		PanicIfError(utils.BuildErrorWithCaller(
+			fmt.Errorf("The controller is closing, no new operation is accepted"),
+		))
+	}
+
+	lifecycle.inFlight.Add(1)
+}
+func (dbController *DbController) endOperation() {
+	if dbController.lifecycle == nil {
+		return
+	}
+
+	dbController.lifecycle.inFlight.Done()
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync/atomic"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbShutdownSuite struct{}
+
+var _ = Suite(&TestRdbShutdownSuite{})
+
+// Tests the shutdown after in-flight operations are finished
+func (suite *TestRdbShutdownSuite) TestShutdownWithDrain(c *C) {
+	started := make(chan struct{})
+	testedCtrl := newFakeDbController(&fakeDriverDb{
+		execFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Result, error) {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			return driver.RowsAffected(1), nil
+		},
+	})
+
+	var finished int32
+	go func() {
+		testedCtrl.Exec("UPDATE nqm_agent SET ag_status = 1")
+		atomic.StoreInt32(&finished, 1)
+	}()
+	<-started
+
+	c.Assert(testedCtrl.Shutdown(context.Background()), IsNil)
+	c.Assert(atomic.LoadInt32(&finished), Equals, int32(1))
+
+	c.Assert(
+		func() { testedCtrl.Exec("UPDATE nqm_agent SET ag_status = 2") },
+		PanicMatches, ".*controller is closing.*",
+	)
+}
+
+// Tests the shutdown forced by timeout of context
+func (suite *TestRdbShutdownSuite) TestShutdownWithTimeout(c *C) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	testedCtrl := newFakeDbController(&fakeDriverDb{
+		execFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Result, error) {
+			close(started)
+			<-release
+			return driver.RowsAffected(1), nil
+		},
+	})
+	testedCtrl.RegisterPanicHandler(func(p interface{}) {})
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		testedCtrl.Exec("UPDATE nqm_agent SET ag_status = 1")
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	c.Assert(testedCtrl.Shutdown(ctx), Equals, context.DeadlineExceeded)
+
+	close(release)
+	<-finished
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"time"
+)
+
+// The logger for query which takes time longer than threshold
+//
+// The arguments are redacted by ArgRedactor if it is set.
+type SlowQueryLogger func(sql string, args []interface{}, elapsed time.Duration)
+
+// Sets the threshold of slow query, the zero value disables the logging of slow query
+//
+// Only the time of calling database is measured, the time of iterating rows by callback is excluded.
+func (dbController *DbController) SetSlowQueryThreshold(d time.Duration) {
+	dbController.slowQueryThreshold = d
+}
+
+// Sets the logger of slow query, the nil value would use Logger
+func (dbController *DbController) SetSlowQueryLogger(logger SlowQueryLogger) {
+	dbController.slowQueryLogger = logger
+}
+
+func (dbController *DbController) checkSlowQuery(sql string, args []interface{}, elapsed time.Duration) {
+	if dbController.slowQueryThreshold <= 0 || elapsed <= dbController.slowQueryThreshold {
+		return
+	}
+
+	args = redactArgs(args)
+
+	if dbController.slowQueryLogger != nil {
+		dbController.slowQueryLogger(sql, args, elapsed)
+		return
+	}
+
+	Logger.Printf("[Slow Query] Elapsed: [%v]. SQL: \"%s\" Params: %#v", elapsed, sql, args)
+}
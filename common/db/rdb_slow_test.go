@@ -0,0 +1,63 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbSlowSuite struct{}
+
+var _ = Suite(&TestRdbSlowSuite{})
+
+// Tests the logging of slow query
+func (suite *TestRdbSlowSuite) TestSlowQueryLogger(c *C) {
+	testedCtrl := newFakeDbController(&fakeDriverDb{
+		execFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Result, error) {
+			if query == "SLOW" {
+				time.Sleep(20 * time.Millisecond)
+			}
+			return driver.RowsAffected(0), nil
+		},
+		queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+			if query == "SLOW" {
+				time.Sleep(20 * time.Millisecond)
+			}
+			return &fakeRows{columns: []string{"v"}, values: [][]driver.Value{{1}, {2}}}, nil
+		},
+	})
+	defer testedCtrl.Release()
+
+	loggedQueries := make([]string, 0)
+	testedCtrl.SetSlowQueryThreshold(10 * time.Millisecond)
+	testedCtrl.SetSlowQueryLogger(func(sql string, args []interface{}, elapsed time.Duration) {
+		c.Assert(elapsed > 10*time.Millisecond, Equals, true)
+		loggedQueries = append(loggedQueries, sql)
+	})
+
+	testedCtrl.Exec("SLOW", 1)
+	testedCtrl.Exec("FAST", 1)
+	testedCtrl.QueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl { return IterateContinue }),
+		"SLOW",
+	)
+	/**
+	 * The slow callback of rows is not counted
+	 */
+	testedCtrl.QueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			time.Sleep(20 * time.Millisecond)
+			return IterateContinue
+		}),
+		"FAST",
+	)
+	// :~)
+	testedCtrl.QueryForRow(
+		RowCallbackFunc(func(row *sql.Row) {}),
+		"SLOW",
+	)
+
+	c.Assert(loggedQueries, DeepEquals, []string{"SLOW", "SLOW", "SLOW"})
+}
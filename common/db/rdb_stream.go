@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// Query for rows and streams the rows into channel
+//
+// Both of the channels are closed after the iteration is finished or the context is done.
+// The error channel gives at most one error(including the error of context).
+//
+// Constraint of consuming: since sql.Rows is not concurrent-safe, every emitted row must be consumed
+// and acknowledged by RowsExt.Ack() before the next one is sent.
+// The cursor is not moved to the next row until the acknowledgement is received(or the context is done).
+//
+//	for row := range rowsChan {
+//		row.Scan(&id)
+//		row.Ack()
+//	}
+//
+// Since only one row is emitted at a time, the "bufferSize" is the capacity of channel but never fills more than one row.
+//
+// The consumer should keep receiving until the channel of rows is closed, or cancel the context to stop the streaming.
+// After the context is done, the unacknowledged row should not be used anymore.
+func (dbController *DbController) QueryStream(
+	ctx context.Context, bufferSize int,
+	sqlQuery string, args ...interface{},
+) (<-chan *RowsExt, <-chan error) {
+	rowsChan := make(chan *RowsExt, bufferSize)
+	errChan := make(chan error, 1)
+
+	go func() {
+		defer close(errChan)
+		defer close(rowsChan)
+
+		var err error
+		capturingCtrl := *dbController
+		capturingCtrl.panicHandlers = nil
+		capturingCtrl.RegisterPanicHandler(NewDbErrorCapture(&err))
+
+		capturingCtrl.QueryForRowsContext(
+			ctx,
+			RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+				ack := make(chan struct{}, 1)
+				streamAcks.Store(rows, ack)
+				defer streamAcks.Delete(rows)
+
+				select {
+				case rowsChan <- ToRowsExt(rows):
+				case <-ctx.Done():
+					return IterateStop
+				}
+
+				select {
+				case <-ack:
+					return IterateContinue
+				case <-ctx.Done():
+					return IterateStop
+				}
+			}),
+			sqlQuery, args...,
+		)
+
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			errChan <- err
+		}
+	}()
+
+	return rowsChan, errChan
+}
+
+// The channels of acknowledgement for rows being emitted by QueryStream()
+var streamAcks sync.Map
+
+// Acknowledges the row emitted by QueryStream() has been consumed, so the cursor could be moved to the next row
+//
+// This method does nothing if the rows are not emitted by QueryStream().
+func (rowsExt *RowsExt) Ack() {
+	ack, ok := streamAcks.Load((*sql.Rows)(rowsExt))
+	if !ok {
+		return
+	}
+
+	select {
+	case ack.(chan struct{}) <- struct{}{}:
+	default:
+	}
+}
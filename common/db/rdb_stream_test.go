@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbStreamSuite struct{}
+
+var _ = Suite(&TestRdbStreamSuite{})
+
+// Tests the streaming of all rows
+func (suite *TestRdbStreamSuite) TestQueryStream(c *C) {
+	testedCtrl := buildSampleStreamDbController(c)
+	defer testedCtrl.Release()
+
+	rowsChan, errChan := testedCtrl.QueryStream(context.Background(), 0, "SELECT ts_id FROM test_stream ORDER BY ts_id")
+
+	ids := make([]int64, 0)
+	for row := range rowsChan {
+		var id int64
+		row.Scan(&id)
+		row.Ack()
+
+		ids = append(ids, id)
+	}
+
+	c.Assert(ids, DeepEquals, []int64{1, 2, 3, 4, 5})
+	c.Assert(<-errChan, IsNil)
+
+	/**
+	 * The cursor has been closed, no connection is kept by streamed rows
+	 */
+	c.Assert(testedCtrl.dbObject.Stats().InUse, Equals, 0)
+	// :~)
+}
+
+// Tests the cancelling of streaming
+func (suite *TestRdbStreamSuite) TestQueryStreamCancelled(c *C) {
+	testedCtrl := buildSampleStreamDbController(c)
+	defer testedCtrl.Release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rowsChan, errChan := testedCtrl.QueryStream(ctx, 0, "SELECT ts_id FROM test_stream ORDER BY ts_id")
+
+	for i := 0; i < 2; i++ {
+		var id int64
+		row := <-rowsChan
+		row.Scan(&id)
+		row.Ack()
+
+		c.Assert(id, Equals, int64(i+1))
+	}
+
+	cancel()
+
+	select {
+	case err := <-errChan:
+		c.Assert(err, ErrorMatches, ".*context canceled.*")
+	case <-time.After(time.Second):
+		c.Fatalf("The goroutine of streaming is not finished")
+	}
+
+	/**
+	 * The channel of rows is closed
+	 */
+	for range rowsChan {
+	}
+	// :~)
+}
+
+// Tests the next row is not sent before the current one is acknowledged
+func (suite *TestRdbStreamSuite) TestQueryStreamWithAck(c *C) {
+	testedCtrl := buildSampleStreamDbController(c)
+	defer testedCtrl.Release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rowsChan, errChan := testedCtrl.QueryStream(ctx, 4, "SELECT ts_id FROM test_stream ORDER BY ts_id")
+
+	var id int64
+	row := <-rowsChan
+
+	select {
+	case <-rowsChan:
+		c.Fatalf("The next row is sent before the current one is acknowledged")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	row.Scan(&id)
+	c.Assert(id, Equals, int64(1))
+	row.Ack()
+
+	row = <-rowsChan
+	row.Scan(&id)
+	c.Assert(id, Equals, int64(2))
+
+	cancel()
+	c.Assert(<-errChan, ErrorMatches, ".*context canceled.*")
+}
+
+func buildSampleStreamDbController(c *C) *DbController {
+	testedCtrl := buildSampleDbController(c)
+
+	testedCtrl.Exec("CREATE TABLE test_stream(ts_id INT PRIMARY KEY)")
+	testedCtrl.Exec("INSERT INTO test_stream VALUES(1), (2), (3), (4), (5)")
+
+	return testedCtrl
+}
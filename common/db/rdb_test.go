@@ -1,7 +1,12 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	. "gopkg.in/check.v1"
@@ -11,6 +16,207 @@ type TestRdbSuite struct{}
 
 var _ = Suite(&TestRdbSuite{})
 
+// Tests the settings of connection pool from configuration
+func (suite *TestRdbSuite) TestToDbController(c *C) {
+	testCases := []*struct {
+		config                 *DbConfig
+		expectedIdle           int
+		expectedMaxOpen        int
+		expectedLifetimeClosed bool
+	}{
+		// The default max idle of "database/sql" is 2
+		{&DbConfig{Dsn: ":memory:", Driver: "sqlite3"}, 2, 0, false},
+		{&DbConfig{Dsn: ":memory:", Driver: "sqlite3", MaxIdle: 1, MaxOpen: 8, ConnMaxLifetime: 20 * time.Millisecond}, 1, 8, true},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		testedCtrl, err := testCase.config.ToDbController()
+		c.Assert(err, IsNil, comment)
+
+		/**
+		 * Uses 3 connections at the same time, then releases them to the pool
+		 */
+		var conns []*sql.Conn
+		for j := 0; j < 3; j++ {
+			conn, err := testedCtrl.dbObject.Conn(context.Background())
+			c.Assert(err, IsNil, comment)
+			conns = append(conns, conn)
+		}
+		for _, conn := range conns {
+			conn.Close()
+		}
+		// :~)
+
+		stats := testedCtrl.dbObject.Stats()
+		c.Assert(stats.MaxOpenConnections, Equals, testCase.expectedMaxOpen, comment)
+		c.Assert(stats.Idle, Equals, testCase.expectedIdle, comment)
+
+		/**
+		 * The expired connection is closed while it is reused
+		 */
+		time.Sleep(30 * time.Millisecond)
+		c.Assert(testedCtrl.Ping(), IsNil, comment)
+		c.Assert(testedCtrl.dbObject.Stats().MaxLifetimeClosed > 0, Equals, testCase.expectedLifetimeClosed, comment)
+		// :~)
+
+		testedCtrl.Release()
+	}
+
+	_, err := (&DbConfig{Dsn: ":memory:", Driver: "no-such-driver"}).ToDbController()
+	c.Assert(err, NotNil)
+}
+
+// Tests the opening of database with pinging
+func (suite *TestRdbSuite) TestOpenDbController(c *C) {
+	testCases := []*struct {
+		config      *DbConfig
+		expectedErr bool
+	}{
+		{&DbConfig{Dsn: ":memory:", Driver: "sqlite3", MaxIdle: 2}, false},
+		{&DbConfig{Dsn: "file:/no-such-dir/sample.db?mode=rw", Driver: "sqlite3"}, true},
+		{&DbConfig{Dsn: ":memory:", Driver: "no-such-driver"}, true},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		testedCtrl, err := OpenDbController(testCase.config)
+		if testCase.expectedErr {
+			c.Assert(err, NotNil, comment)
+			c.Assert(testedCtrl, IsNil, comment)
+			continue
+		}
+
+		c.Assert(err, IsNil, comment)
+		c.Assert(testedCtrl.Ping(), IsNil, comment)
+		testedCtrl.Release()
+	}
+}
+
+// Tests the password of DSN is not in the error of opening
+func (suite *TestRdbSuite) TestOpenDbControllerWithRedactedDsn(c *C) {
+	config := &DbConfig{Dsn: "root:my-secret@tcp(127.0.0.1:1)/falcon_portal?timeout=100ms"}
+
+	_, err := OpenDbController(config)
+	c.Assert(err, NotNil)
+	c.Assert(err, Not(ErrorMatches), ".*my-secret.*")
+	c.Assert(err, ErrorMatches, `(?s).*root:\*\*\*@tcp\(127\.0\.0\.1:1\)/falcon_portal.*`)
+
+	c.Assert(config.String(), Not(Matches), ".*my-secret.*")
+	c.Assert((&DbConfig{Dsn: "root:my-secret@invalid"}).String(), Not(Matches), ".*my-secret.*")
+}
+
+var (
+	fakeValidationDb           = &fakeDriverDb{}
+	registerFakeValidationOnce sync.Once
+)
+
+// Tests the validation query executed at open time
+func (suite *TestRdbSuite) TestOpenDbControllerWithValidationQuery(c *C) {
+	registerFakeValidationOnce.Do(func() {
+		sql.Register("fake-validation", fakeValidationDb.Driver())
+	})
+
+	var failed, failedRows bool
+	fakeValidationDb.queryFunc = func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+		if failed {
+			return nil, errors.New("proxy is not ready")
+		}
+
+		rows := &fakeRows{columns: []string{"1"}, values: [][]driver.Value{{int64(1)}}}
+		if failedRows {
+			rows.nextErr = errors.New("connection is lost while reading")
+		}
+		return rows, nil
+	}
+	defer func() { fakeValidationDb.queryFunc = nil }()
+
+	config := &DbConfig{Driver: "fake-validation", ValidationQuery: "SELECT 1 /* validation */"}
+
+	testedCtrl, err := OpenDbController(config)
+	c.Assert(err, IsNil)
+	c.Assert(fakeValidationDb.executed(), DeepEquals, []string{"SELECT 1 /* validation */"})
+	testedCtrl.Release()
+
+	failed = true
+	_, err = OpenDbController(config)
+	c.Assert(err, ErrorMatches, ".*Validation query has error: proxy is not ready.*")
+
+	/**
+	 * The error of reading rows fails the validation
+	 */
+	failed, failedRows = false, true
+	_, err = OpenDbController(config)
+	c.Assert(err, ErrorMatches, ".*Validation query has error: connection is lost while reading.*")
+	// :~)
+}
+
+// Tests the limit of rows processed by callback
+func (suite *TestRdbSuite) TestLimitRows(c *C) {
+	testCases := []*struct {
+		limit        uint
+		innerStopAt  int
+		expectedRows []int64
+	}{
+		{3, 0, []int64{1, 2, 3}},
+		{10, 0, []int64{1, 2, 3, 4, 5}},
+		{3, 2, []int64{1, 2}},
+		{0, 0, nil},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		var scannedRows int
+		testedCtrl := newFakeDbController(&fakeDriverDb{
+			queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+				return &fakeRows{
+					columns: []string{"v"},
+					values:  [][]driver.Value{{int64(1)}, {int64(2)}, {int64(3)}, {int64(4)}, {int64(5)}},
+				}, nil
+			},
+		})
+
+		var processedRows []int64
+		testedCtrl.QueryForRows(
+			LimitRows(testCase.limit, RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+				var v int64
+				ToRowsExt(rows).Scan(&v)
+				processedRows = append(processedRows, v)
+
+				scannedRows++
+				if scannedRows == testCase.innerStopAt {
+					return IterateStop
+				}
+				return IterateContinue
+			})),
+			"SELECT v FROM sample_rows",
+		)
+
+		c.Assert(processedRows, DeepEquals, testCase.expectedRows, comment)
+
+		testedCtrl.Release()
+	}
+}
+
+// Tests the cloned configuration with different sizes of connection pool
+func (suite *TestRdbSuite) TestCloneWith(c *C) {
+	sourceConfig := &DbConfig{Dsn: ":memory:", Driver: "sqlite3", MaxIdle: 4, MaxOpen: 16, ConnMaxLifetime: time.Minute}
+
+	clonedConfig := sourceConfig.CloneWith(1, 2)
+	c.Assert(clonedConfig, DeepEquals, &DbConfig{Dsn: ":memory:", Driver: "sqlite3", MaxIdle: 1, MaxOpen: 2, ConnMaxLifetime: time.Minute})
+	c.Assert(sourceConfig.MaxIdle, Equals, 4)
+	c.Assert(sourceConfig.MaxOpen, Equals, 16)
+
+	testedCtrl, err := OpenDbControllerWith(sourceConfig, 1, 2)
+	c.Assert(err, IsNil)
+	defer testedCtrl.Release()
+
+	c.Assert(testedCtrl.dbObject.Stats().MaxOpenConnections, Equals, 2)
+}
+
 // Tests the panic(no panic handler)
 func (suite *TestRdbSuite) TestOperateOnDbWithPanic(c *C) {
 	testedCtrl := buildSampleDbController(c)
@@ -59,6 +265,24 @@ func (suite *TestRdbSuite) TestRelease(c *C) {
 	testedCtrl.Release()
 }
 
+// Tests the ping to database
+func (suite *TestRdbSuite) TestPing(c *C) {
+	testedCtrl := buildSampleDbController(c)
+
+	c.Assert(testedCtrl.Ping(), IsNil)
+	c.Assert(testedCtrl.Stats().OpenConnections, Equals, 1)
+
+	/**
+	 * Ping to closed database
+	 */
+	testedCtrl.dbObject.Close()
+	c.Assert(testedCtrl.Ping(), NotNil)
+	// :~)
+
+	testedCtrl.Release()
+	c.Assert(testedCtrl.Ping(), NotNil)
+}
+
 // Tests the register of handler
 func (suite *TestRdbSuite) TestRegisterPanicHandler(c *C) {
 	testedCtrl := buildSampleDbController(c)
@@ -112,6 +336,148 @@ func (suite *TestRdbSuite) TestQueryForRows(c *C) {
 	c.Assert(testedNumberOfRows, Equals, uint(3))
 }
 
+// Tests the query for rows with index
+func (suite *TestRdbSuite) TestQueryForRowsIndexed(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec("CREATE TABLE test_rows_idx(tr_id INT PRIMARY KEY)")
+	testedCtrl.Exec("INSERT INTO test_rows_idx VALUES(1), (2), (3), (4), (5)")
+
+	visitedIndexes := make([]uint, 0)
+	testedNumberOfRows := testedCtrl.QueryForRowsIndexed(
+		IndexedRowsCallbackFunc(func(index uint, rows *sql.Rows) IterateControl {
+			visitedIndexes = append(visitedIndexes, index)
+			if index == 2 {
+				return IterateStop
+			}
+
+			return IterateContinue
+		}),
+		"SELECT * FROM test_rows_idx",
+	)
+
+	c.Assert(testedNumberOfRows, Equals, uint(3))
+	c.Assert(visitedIndexes, DeepEquals, []uint{0, 1, 2})
+}
+
+// Tests the query for rows with cancelled context
+func (suite *TestRdbSuite) TestQueryForRowsContext(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec(
+		"CREATE TABLE test_rows_ctx(tr_id INT PRIMARY KEY)",
+	)
+	testedCtrl.Exec(
+		"INSERT INTO test_rows_ctx VALUES(1), (2), (3), (4), (5)",
+	)
+
+	var capturedPanic interface{}
+	testedCtrl.RegisterPanicHandler(func(p interface{}) {
+		capturedPanic = p
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	numberOfCalled := 0
+	testedCtrl.QueryForRowsContext(
+		ctx,
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			numberOfCalled++
+			if numberOfCalled == 2 {
+				cancel()
+			}
+
+			return IterateContinue
+		}),
+		"SELECT * FROM test_rows_ctx ORDER BY tr_id",
+	)
+
+	c.Assert(numberOfCalled, Equals, 2)
+	c.Assert(capturedPanic, ErrorMatches, ".*context canceled.*")
+}
+
+// Tests the error of iteration is only raised by the method with context
+func (suite *TestRdbSuite) TestQueryForRowsWithErrorOfIteration(c *C) {
+	testedCtrl := newFakeDbController(&fakeDriverDb{
+		queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+			return &fakeRows{
+				columns: []string{"v"}, values: [][]driver.Value{{int64(1)}},
+				nextErr: errors.New("sample error of iteration"),
+			}, nil
+		},
+	})
+	defer testedCtrl.Release()
+
+	callback := RowsCallbackFunc(func(rows *sql.Rows) IterateControl { return IterateContinue })
+
+	c.Assert(testedCtrl.QueryForRows(callback, "SELECT v FROM t"), Equals, uint(1))
+	c.Assert(
+		func() { testedCtrl.QueryForRowsContext(context.Background(), callback, "SELECT v FROM t") },
+		PanicMatches, ".*sample error of iteration.*",
+	)
+}
+
+// Tests the executing with cancelled context
+func (suite *TestRdbSuite) TestExecContext(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c.Assert(
+		func() { testedCtrl.ExecContext(ctx, "CREATE TABLE test_exec_ctx(te_id INT)") },
+		PanicMatches, ".*context canceled.*",
+	)
+}
+
+// Tests the default timeout of query
+func (suite *TestRdbSuite) TestSetDefaultQueryTimeout(c *C) {
+	blockingQuery := func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+		<-conn.ctx.Done()
+		return nil, conn.ctx.Err()
+	}
+
+	testCases := []*struct {
+		defaultTimeout  time.Duration
+		callerTimeout   time.Duration
+		expectedTimeout time.Duration
+	}{
+		{50 * time.Millisecond, time.Minute, 50 * time.Millisecond},
+		{time.Minute, 50 * time.Millisecond, 50 * time.Millisecond},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		testedCtrl := newFakeDbController(&fakeDriverDb{queryFunc: blockingQuery})
+		testedCtrl.SetDefaultQueryTimeout(testCase.defaultTimeout)
+
+		ctx, cancel := context.WithTimeout(context.Background(), testCase.callerTimeout)
+
+		startTime := time.Now()
+		c.Assert(
+			func() {
+				testedCtrl.QueryForRowsContext(
+					ctx,
+					RowsCallbackFunc(func(rows *sql.Rows) IterateControl { return IterateContinue }),
+					"SELECT SLEEP(60)",
+				)
+			},
+			PanicMatches, ".*deadline exceeded.*", comment,
+		)
+
+		elapsed := time.Since(startTime)
+		c.Assert(elapsed >= testCase.expectedTimeout && elapsed < time.Second, Equals, true, comment)
+
+		cancel()
+		testedCtrl.Release()
+	}
+}
+
 // Tests the query for row
 func (suite *TestRdbSuite) TestQueryForRow(c *C) {
 	testedCtrl := buildSampleDbController(c)
@@ -175,6 +541,275 @@ func (suite *TestRdbSuite) TestInTx(c *C) {
 	// :~)
 }
 
+// Tests the executing in transaction with context cancelled before committing
+func (suite *TestRdbSuite) TestInTxContext(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec(
+		"CREATE TABLE test_in_tx(it_id INT PRIMARY KEY, it_text VARCHAR(64) NOT NULL)",
+	)
+
+	/**
+	 * Cancelled context with commit
+	 */
+	var testedFunc = func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		testedCtrl.InTxContext(ctx, TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+			ToTxExt(tx).Exec("INSERT INTO test_in_tx VALUES(31, 'v-31')")
+			cancel()
+
+			return TxCommit
+		}))
+	}
+
+	c.Assert(testedFunc, PanicMatches, ".*context canceled.*")
+	assertNumberOfDataInTx(c, testedCtrl, 0)
+	// :~)
+
+	/**
+	 * Cancelled context with rollback
+	 */
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	testedCtrl.InTxContext(ctx, TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		ToTxExt(tx).Exec("INSERT INTO test_in_tx VALUES(32, 'v-32')")
+		cancel()
+
+		return TxRollback
+	}))
+	assertNumberOfDataInTx(c, testedCtrl, 0)
+	// :~)
+}
+
+// Tests the executing of prepared statement for multiple sets of arguments
+func (suite *TestRdbSuite) TestTxExtExecMany(c *C) {
+	fakeDb := &fakeDriverDb{}
+	testedCtrl := newFakeDbController(fakeDb)
+	defer testedCtrl.Release()
+
+	insertSql := "INSERT INTO test_in_tx VALUES(?, ?)"
+
+	var testedResults []sql.Result
+	testedCtrl.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		testedResults = ToTxExt(tx).ExecMany(
+			insertSql,
+			[][]interface{}{{61, "v-61"}, {62, "v-62"}, {63, "v-63"}},
+		)
+		return TxCommit
+	}))
+
+	c.Assert(testedResults, HasLen, 3)
+	c.Assert(fakeDb.prepared(insertSql), Equals, 1)
+	c.Assert(fakeDb.executed(), DeepEquals, []string{insertSql, insertSql, insertSql})
+}
+
+// Tests the arguments of query in transaction
+func (suite *TestRdbSuite) TestTxExtQuery(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.ExecQueriesInTx(
+		"CREATE TABLE test_in_tx(it_id INT PRIMARY KEY, it_text VARCHAR(64) NOT NULL)",
+		"INSERT INTO test_in_tx VALUES(71, 'v-71'), (72, 'v-72')",
+	)
+
+	var testedTexts []string
+	testedCtrl.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		rows := ToTxExt(tx).Query("SELECT it_text FROM test_in_tx WHERE it_id = ?", 72)
+		defer rows.Close()
+
+		for rows.Next() {
+			var text string
+			ToRowsExt(rows).Scan(&text)
+			testedTexts = append(testedTexts, text)
+		}
+
+		return TxCommit
+	}))
+
+	c.Assert(testedTexts, DeepEquals, []string{"v-72"})
+}
+
+// Tests the reading of written rows in the same transaction
+func (suite *TestRdbSuite) TestTxExtQueryForRows(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec("CREATE TABLE test_in_tx(it_id INT PRIMARY KEY, it_text VARCHAR(64) NOT NULL)")
+
+	var testedTexts []string
+	var testedCount int
+	var numberOfRows uint
+	testedCtrl.InTx(TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+		txExt := ToTxExt(tx)
+		txExt.Exec("INSERT INTO test_in_tx VALUES(81, 'v-81'), (82, 'v-82')")
+
+		numberOfRows = txExt.QueryForRows(
+			RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+				var text string
+				ToRowsExt(rows).Scan(&text)
+				testedTexts = append(testedTexts, text)
+				return IterateContinue
+			}),
+			"SELECT it_text FROM test_in_tx WHERE it_id >= ? ORDER BY it_id", 81,
+		)
+		txExt.QueryForRow(
+			RowCallbackFunc(func(row *sql.Row) { ToRowExt(row).Scan(&testedCount) }),
+			"SELECT COUNT(*) FROM test_in_tx",
+		)
+
+		return TxRollback
+	}))
+
+	c.Assert(numberOfRows, Equals, uint(2))
+	c.Assert(testedTexts, DeepEquals, []string{"v-81", "v-82"})
+	c.Assert(testedCount, Equals, 2)
+
+	/**
+	 * The rows are not visible after rollback
+	 */
+	testedCtrl.QueryForRow(
+		RowCallbackFunc(func(row *sql.Row) { ToRowExt(row).Scan(&testedCount) }),
+		"SELECT COUNT(*) FROM test_in_tx",
+	)
+	c.Assert(testedCount, Equals, 0)
+	// :~)
+}
+
+// Tests the transaction controlled by caller
+func (suite *TestRdbSuite) TestBeginAndFinish(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec("CREATE TABLE test_in_tx(it_id INT PRIMARY KEY, it_text VARCHAR(64) NOT NULL)")
+
+	insertRow := func(id int, resultErr error) (err error) {
+		txExt := testedCtrl.Begin()
+		defer txExt.Finish(&err)
+
+		txExt.Exec("INSERT INTO test_in_tx VALUES(?, 'v')", id)
+		return resultErr
+	}
+	errSample := errors.New("sample error")
+
+	testCases := []*struct {
+		id            int
+		resultErr     error
+		expectedCount int
+	}{
+		{91, nil, 1},
+		{92, errSample, 0},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		c.Assert(insertRow(testCase.id, testCase.resultErr), Equals, testCase.resultErr, comment)
+
+		var count int
+		testedCtrl.QueryForRow(
+			RowCallbackFunc(func(row *sql.Row) { ToRowExt(row).Scan(&count) }),
+			"SELECT COUNT(*) FROM test_in_tx WHERE it_id = ?", testCase.id,
+		)
+		c.Assert(count, Equals, testCase.expectedCount, comment)
+	}
+
+	/**
+	 * Rollback by panic
+	 */
+	c.Assert(
+		func() {
+			var err error
+			txExt := testedCtrl.Begin()
+			defer txExt.Finish(&err)
+
+			txExt.Exec("INSERT INTO test_in_tx VALUES(93, 'v')")
+			panic("sample panic")
+		},
+		PanicMatches, "sample panic",
+	)
+
+	var count int
+	testedCtrl.QueryForRow(
+		RowCallbackFunc(func(row *sql.Row) { ToRowExt(row).Scan(&count) }),
+		"SELECT COUNT(*) FROM test_in_tx WHERE it_id = 93",
+	)
+	c.Assert(count, Equals, 0)
+	// :~)
+}
+
+// Tests the writing in read-only transaction
+func (suite *TestRdbSuite) TestInTxWithOpts(c *C) {
+	testedCtrl := newFakeDbController(&fakeDriverDb{})
+	defer testedCtrl.Release()
+
+	var testedFunc = func() {
+		testedCtrl.InTxWithOpts(
+			&sql.TxOptions{ReadOnly: true},
+			TxCallbackFunc(func(tx *sql.Tx) TxFinale {
+				ToTxExt(tx).Exec("INSERT INTO test_in_tx VALUES(41, 'v-41')")
+				return TxCommit
+			}),
+		)
+	}
+
+	c.Assert(testedFunc, PanicMatches, ".*READ ONLY.*")
+}
+
+// Tests the reads in snapshot are consistent with concurrent write
+func (suite *TestRdbSuite) TestInTxSnapshot(c *C) {
+	db, err := sql.Open("sqlite3", c.MkDir()+"/snapshot.db")
+	c.Assert(err, IsNil)
+	testedCtrl := NewDbController(db)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec("PRAGMA journal_mode=WAL")
+	testedCtrl.Exec("CREATE TABLE test_snapshot(ts_id INT PRIMARY KEY)")
+	testedCtrl.Exec("INSERT INTO test_snapshot VALUES(1), (2)")
+
+	countRows := func(rowQuery func(rowCallback RowCallback, query string, args ...interface{})) int {
+		var count int
+		rowQuery(
+			RowCallbackFunc(func(row *sql.Row) { ToRowExt(row).Scan(&count) }),
+			"SELECT COUNT(*) FROM test_snapshot",
+		)
+		return count
+	}
+
+	var firstCount, secondCount int
+	testedCtrl.InTxSnapshot(func(txExt *TxExt) {
+		firstCount = countRows(txExt.QueryForRow)
+
+		testedCtrl.Exec("INSERT INTO test_snapshot VALUES(3)")
+
+		secondCount = countRows(txExt.QueryForRow)
+	})
+
+	c.Assert(firstCount, Equals, 2)
+	c.Assert(secondCount, Equals, 2)
+	c.Assert(countRows(testedCtrl.QueryForRow), Equals, 3)
+}
+
+// Tests the writing in snapshot
+func (suite *TestRdbSuite) TestInTxSnapshotWithWrite(c *C) {
+	fakeDb := &fakeDriverDb{}
+	testedCtrl := newFakeDbController(fakeDb)
+	defer testedCtrl.Release()
+
+	c.Assert(
+		func() {
+			testedCtrl.InTxSnapshot(func(txExt *TxExt) {
+				txExt.Exec("INSERT INTO test_snapshot VALUES(4)")
+			})
+		},
+		PanicMatches, ".*READ ONLY.*",
+	)
+}
+
 // Tests the calling of if callbacks in transaction
 func (suite *TestRdbSuite) TestInTxForIf(c *C) {
 	testedCtrl := buildSampleDbController(c)
@@ -190,6 +825,47 @@ func (suite *TestRdbSuite) TestInTxForIf(c *C) {
 	c.Assert(testedFalseSample.getCalled, Equals, false)
 }
 
+// Tests the cancellation of context while calling if callbacks in transaction
+func (suite *TestRdbSuite) TestInTxForIfContext(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec(
+		"CREATE TABLE test_in_tx(it_id INT PRIMARY KEY, it_text VARCHAR(64) NOT NULL)",
+	)
+
+	/**
+	 * Cancelled context before IfTrue() completes
+	 */
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	testedSample := &cancelIfSample{cancel: cancel}
+	testedFunc := func() {
+		testedCtrl.InTxForIfContext(ctx, testedSample)
+	}
+
+	c.Assert(testedFunc, PanicMatches, ".*context canceled.*")
+	c.Assert(testedSample.getCalled, Equals, true)
+	assertNumberOfDataInTx(c, testedCtrl, 0)
+	// :~)
+
+	/**
+	 * Cancelled context before calling of IfTrue()
+	 */
+	testedTrueSample := &ifSample{true, false}
+	c.Assert(func() { testedCtrl.InTxForIfContext(ctx, testedTrueSample) }, PanicMatches, ".*context canceled.*")
+	c.Assert(testedTrueSample.getCalled, Equals, false)
+	// :~)
+
+	/**
+	 * Normal context
+	 */
+	testedCtrl.InTxForIfContext(context.Background(), testedTrueSample)
+	c.Assert(testedTrueSample.getCalled, Equals, true)
+	// :~)
+}
+
 // Tests the executing of queries in transaction
 func (suite *TestRdbSuite) TestExecQueriesInTx(c *C) {
 	testedCtrl := buildSampleDbController(c)
@@ -216,6 +892,48 @@ func (suite *TestRdbSuite) TestExecQueriesInTx(c *C) {
 	c.Assert(numberOfRows, Equals, 3)
 }
 
+// Tests the number of affected rows of queries in transaction
+func (suite *TestRdbSuite) TestExecQueriesInTxCounting(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.ExecQueriesInTx(
+		"CREATE TABLE car_982 ( c_id INT PRIMARY KEY, c_name VARCHAR(64) NOT NULL )",
+		"INSERT INTO car_982 VALUES(1, 'OK-1')",
+		"INSERT INTO car_982 VALUES(2, 'OK-2')",
+		"INSERT INTO car_982 VALUES(3, 'OK-3')",
+	)
+
+	testedResult := testedCtrl.ExecQueriesInTxCounting(
+		"UPDATE car_982 SET c_name = 'UP-1' WHERE c_id <= 2",
+		"UPDATE car_982 SET c_name = 'UP-2' WHERE c_id = 3",
+	)
+	c.Assert(testedResult, DeepEquals, []int64{2, 1})
+
+	/**
+	 * Asserts the rollback of transaction
+	 */
+	c.Assert(
+		func() {
+			testedCtrl.ExecQueriesInTxCounting(
+				"UPDATE car_982 SET c_name = 'UP-3'",
+				"UPDATE car_982 SET no_such_column = 1",
+			)
+		},
+		PanicMatches, "(?i).*no_such_column.*",
+	)
+
+	var numberOfRows int
+	testedCtrl.QueryForRow(
+		RowCallbackFunc(func(row *sql.Row) {
+			ToRowExt(row).Scan(&numberOfRows)
+		}),
+		"SELECT COUNT(*) FROM car_982 WHERE c_name = 'UP-3'",
+	)
+	c.Assert(numberOfRows, Equals, 0)
+	// :~)
+}
+
 type ifSample struct {
 	ifValue   bool
 	getCalled bool
@@ -228,6 +946,22 @@ func (self *ifSample) IfTrue(tx *sql.Tx) {
 	self.getCalled = true
 }
 
+// Inserts a row and cancels the context in IfTrue()
+type cancelIfSample struct {
+	cancel    context.CancelFunc
+	getCalled bool
+}
+
+func (self *cancelIfSample) BootCallback(tx *sql.Tx) bool {
+	return true
+}
+func (self *cancelIfSample) IfTrue(tx *sql.Tx) {
+	self.getCalled = true
+
+	ToTxExt(tx).Exec("INSERT INTO test_in_tx VALUES(51, 'v-51')")
+	self.cancel()
+}
+
 func assertNumberOfDataInTx(
 	c *C,
 	testedCtrl *DbController, expectedResult int,
@@ -244,6 +978,99 @@ func assertNumberOfDataInTx(
 	c.Assert(testedNumber, Equals, expectedResult)
 }
 
+// Tests the operating on a single connection
+func (suite *TestRdbSuite) TestOnConn(c *C) {
+	var usedConns []*fakeConn
+	fakeDb := &fakeDriverDb{
+		queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+			usedConns = append(usedConns, conn)
+			return &fakeRows{}, nil
+		},
+	}
+
+	testedCtrl := newFakeDbController(fakeDb)
+	defer testedCtrl.Release()
+
+	/**
+	 * Holds another connection to ensure the pool has multiple connections
+	 */
+	otherConn, err := testedCtrl.dbObject.Conn(context.Background())
+	c.Assert(err, IsNil)
+	// :~)
+
+	testedCtrl.OnConn(context.Background(), func(conn *sql.Conn) {
+		for i := 0; i < 2; i++ {
+			rows, err := conn.QueryContext(context.Background(), "SELECT LAST_INSERT_ID()")
+			c.Assert(err, IsNil)
+			rows.Close()
+		}
+	})
+	otherConn.Close()
+
+	c.Assert(usedConns, HasLen, 2)
+	c.Assert(usedConns[0], Equals, usedConns[1])
+
+	/**
+	 * The connection is released before the panic is handled
+	 */
+	var inUseOnPanic = -1
+	testedCtrl.RegisterPanicHandler(func(panicValue interface{}) {
+		inUseOnPanic = testedCtrl.Stats().InUse
+	})
+	testedCtrl.OnConn(context.Background(), func(conn *sql.Conn) {
+		panic("Error in function")
+	})
+
+	c.Assert(inUseOnPanic, Equals, 0)
+	// :~)
+}
+
+// Tests the selective handling of panic by filters
+func (suite *TestRdbSuite) TestRegisterPanicFilter(c *C) {
+	/**
+	 * Propagates the panic if none of the filters has handled it
+	 */
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	var loggedPanics []interface{}
+	testedCtrl.RegisterPanicFilter(func(panicValue interface{}) bool {
+		loggedPanics = append(loggedPanics, panicValue)
+		return false
+	})
+
+	c.Assert(
+		func() { testedCtrl.Exec("No Such SQL Stmt") },
+		PanicMatches, ".*syntax error.*",
+	)
+	c.Assert(loggedPanics, HasLen, 1)
+	// :~)
+
+	/**
+	 * Suppresses the panic by a filter or a handler
+	 */
+	var handledByFilter = false
+	testedCtrl.RegisterPanicFilter(func(panicValue interface{}) bool {
+		handledByFilter = true
+		return true
+	})
+	testedCtrl.Exec("No Such SQL Stmt")
+
+	c.Assert(handledByFilter, Equals, true)
+	c.Assert(loggedPanics, HasLen, 2)
+
+	anotherCtrl := buildSampleDbController(c)
+	defer anotherCtrl.Release()
+
+	var handledByHandler = false
+	anotherCtrl.RegisterPanicFilter(func(panicValue interface{}) bool { return false })
+	anotherCtrl.RegisterPanicHandler(func(panicValue interface{}) { handledByHandler = true })
+	anotherCtrl.Exec("No Such SQL Stmt")
+
+	c.Assert(handledByHandler, Equals, true)
+	// :~)
+}
+
 func buildSampleDbController(c *C) *DbController {
 	db, err := sql.Open("sqlite3", ":memory:")
 
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	or "github.com/Cepave/open-falcon-backend/common/runtime"
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// Enables or disables TruncateTables(), which is disabled by default
+//
+// This method should only be called by tests, so that the tables of production could not be truncated accidentally.
+func (dbController *DbController) SetTruncateTablesEnabled(enabled bool) {
+	dbController.truncateTablesEnabled = enabled
+}
+
+// Truncates the tables of MySQL with checks of foreign key disabled, which is used to reset database between tests
+//
+//	dbController.SetTruncateTablesEnabled(true)
+//	dbController.TruncateTables("nqm_log", "nqm_target")
+//
+// The statements are executed on a single connection, so that "SET FOREIGN_KEY_CHECKS" takes effect on the session.
+// The checks of foreign key are re-enabled even if truncating of a table has failed.
+//
+// The panic is raised if the method is not enabled by SetTruncateTablesEnabled().
+func (dbController *DbController) TruncateTables(tables ...string) {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	if !dbController.truncateTablesEnabled {
+		PanicIfError(utils.BuildErrorWithCaller(
+			fmt.Errorf("Truncating of tables is not enabled. Uses SetTruncateTablesEnabled(true) in tests"),
+		))
+	}
+
+	if dbController.dryRun {
+		for _, table := range tables {
+			dbController.logDryRun(buildTruncateTableSql(table), nil)
+		}
+		return
+	}
+
+	ctx := context.Background()
+	dbController.OnConn(ctx, func(conn *sql.Conn) {
+		execOnConn(ctx, conn, "SET FOREIGN_KEY_CHECKS = 0")
+		defer execOnConn(ctx, conn, "SET FOREIGN_KEY_CHECKS = 1")
+
+		for _, table := range tables {
+			execOnConn(ctx, conn, buildTruncateTableSql(table))
+		}
+	})
+}
+
+func buildTruncateTableSql(table string) string {
+	return fmt.Sprintf("TRUNCATE TABLE %s", table)
+}
+
+func execOnConn(ctx context.Context, conn *sql.Conn, query string) {
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		PanicIfError(newSqlError("exec", query, nil, err, or.GetCallerInfo()))
+	}
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbTruncateSuite struct{}
+
+var _ = Suite(&TestRdbTruncateSuite{})
+
+// Tests the truncating of tables on a single connection with checks of foreign key disabled
+func (suite *TestRdbTruncateSuite) TestTruncateTables(c *C) {
+	var lock sync.Mutex
+	rowCounts := map[string]int64{"nqm_log": 20, "nqm_target": 10}
+	usedConns := map[*fakeConn]bool{}
+
+	fakeDb := &fakeDriverDb{
+		execFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Result, error) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			usedConns[conn] = true
+			if table := strings.TrimPrefix(query, "TRUNCATE TABLE "); table != query {
+				if table == "no_such_table" {
+					return nil, errors.New("table doesn't exist")
+				}
+				rowCounts[table] = 0
+			}
+			return driver.RowsAffected(0), nil
+		},
+		queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			table := strings.TrimPrefix(query, "SELECT COUNT(*) FROM ")
+			return &fakeRows{columns: []string{"count"}, values: [][]driver.Value{{rowCounts[table]}}}, nil
+		},
+	}
+	testedCtrl := newFakeDbController(fakeDb)
+	defer testedCtrl.Release()
+
+	/**
+	 * Not enabled
+	 */
+	c.Assert(
+		func() { testedCtrl.TruncateTables("nqm_log") },
+		PanicMatches, ".*not enabled.*",
+	)
+	c.Assert(fakeDb.executed(), HasLen, 0)
+	// :~)
+
+	testedCtrl.SetTruncateTablesEnabled(true)
+	testedCtrl.TruncateTables("nqm_log", "nqm_target")
+
+	c.Assert(fakeDb.executed(), DeepEquals, []string{
+		"SET FOREIGN_KEY_CHECKS = 0",
+		"TRUNCATE TABLE nqm_log",
+		"TRUNCATE TABLE nqm_target",
+		"SET FOREIGN_KEY_CHECKS = 1",
+	})
+	c.Assert(usedConns, HasLen, 1)
+
+	for _, table := range []string{"nqm_log", "nqm_target"} {
+		var count int64
+		testedCtrl.QueryForRow(
+			RowCallbackFunc(func(row *sql.Row) { ToRowExt(row).Scan(&count) }),
+			"SELECT COUNT(*) FROM "+table,
+		)
+		c.Assert(count, Equals, int64(0), Commentf("Table: %s", table))
+	}
+
+	/**
+	 * The checks of foreign key are re-enabled after failure
+	 */
+	c.Assert(
+		func() { testedCtrl.TruncateTables("no_such_table") },
+		PanicMatches, ".*table doesn't exist.*",
+	)
+	executed := fakeDb.executed()
+	c.Assert(executed[len(executed)-1], Equals, "SET FOREIGN_KEY_CHECKS = 1")
+	// :~)
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// Inserts a row or updates the existing one by "ON DUPLICATE KEY UPDATE" of MySQL:
+//
+//	INSERT INTO <table>(<col_1>, <col_2>) VALUES(?, ?)
+//	ON DUPLICATE KEY UPDATE <col_2> = VALUES(<col_2>)
+//
+// The columns of insertion are sorted by their names to build the same SQL for the same columns.
+//
+// If "updateCols" is empty, all of the columns of insertion are updated.
+// The panic is raised if the table or columns of insertion is empty, or a column of update is not inserted,
+// which could be captured by registered PanicHandlers(the result is nil if the panic is handled).
+func (dbController *DbController) Upsert(table string, insertCols map[string]interface{}, updateCols []string) sql.Result {
+	defer utils.DeferCatchPanicWithCaller()()
+
+	if table == "" || len(insertCols) == 0 {
+		dbController.raiseToHandlers(fmt.Errorf("Need table and columns for upsert. Table: [%s]", table))
+		return nil
+	}
+
+	columns := make([]string, 0, len(insertCols))
+	for column := range insertCols {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	if len(updateCols) == 0 {
+		updateCols = columns
+	}
+	for _, column := range updateCols {
+		if _, ok := insertCols[column]; !ok {
+			dbController.raiseToHandlers(fmt.Errorf("Column of update is not inserted: [%s]. Table: [%s]", column, table))
+			return nil
+		}
+	}
+
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		args[i] = insertCols[column]
+	}
+
+	return dbController.Exec(buildUpsertSql(table, columns, updateCols), args...)
+}
+
+func buildUpsertSql(table string, columns []string, updateCols []string) string {
+	updates := make([]string, len(updateCols))
+	for i, column := range updateCols {
+		updates[i] = fmt.Sprintf("%s = VALUES(%s)", column, column)
+	}
+
+	return fmt.Sprintf(
+		"%s ON DUPLICATE KEY UPDATE %s",
+		buildBulkInsertSql(table, columns, 1), strings.Join(updates, ", "),
+	)
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"database/sql/driver"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRdbUpsertSuite struct{}
+
+var _ = Suite(&TestRdbUpsertSuite{})
+
+// Tests the generated SQL of upsert
+func (suite *TestRdbUpsertSuite) TestUpsert(c *C) {
+	testCases := []*struct {
+		updateCols   []string
+		expectedSql  string
+		expectedArgs []interface{}
+	}{
+		{
+			[]string{"mm_value"},
+			"INSERT INTO metric_meta(mm_name, mm_updated, mm_value) VALUES(?, ?, ?) ON DUPLICATE KEY UPDATE mm_value = VALUES(mm_value)",
+			[]interface{}{"cpu.idle", int64(20), int64(10)},
+		},
+		{
+			nil,
+			"INSERT INTO metric_meta(mm_name, mm_updated, mm_value) VALUES(?, ?, ?) ON DUPLICATE KEY UPDATE " +
+				"mm_name = VALUES(mm_name), mm_updated = VALUES(mm_updated), mm_value = VALUES(mm_value)",
+			[]interface{}{"cpu.idle", int64(20), int64(10)},
+		},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		var sentArgs []interface{}
+		fakeDb := &fakeDriverDb{
+			execFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Result, error) {
+				for _, arg := range args {
+					sentArgs = append(sentArgs, arg.Value)
+				}
+				return driver.RowsAffected(1), nil
+			},
+		}
+		testedCtrl := newFakeDbController(fakeDb)
+
+		testedCtrl.Upsert(
+			"metric_meta",
+			map[string]interface{}{"mm_value": 10, "mm_name": "cpu.idle", "mm_updated": 20},
+			testCase.updateCols,
+		)
+
+		c.Assert(fakeDb.executed(), DeepEquals, []string{testCase.expectedSql}, comment)
+		c.Assert(sentArgs, DeepEquals, testCase.expectedArgs, comment)
+
+		testedCtrl.Release()
+	}
+}
+
+// Tests the validation of inputs of upsert
+func (suite *TestRdbUpsertSuite) TestUpsertWithInvalidInputs(c *C) {
+	testedCtrl := newFakeDbController(&fakeDriverDb{})
+	defer testedCtrl.Release()
+
+	testCases := []*struct {
+		table           string
+		insertCols      map[string]interface{}
+		updateCols      []string
+		expectedMessage string
+	}{
+		{"", map[string]interface{}{"mm_name": "v"}, nil, ".*Need table and columns.*"},
+		{"metric_meta", map[string]interface{}{}, nil, ".*Need table and columns.*"},
+		{"metric_meta", map[string]interface{}{"mm_name": "v"}, []string{"mm_value"}, `.*not inserted: \[mm_value\].*`},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		c.Assert(
+			func() { testedCtrl.Upsert(testCase.table, testCase.insertCols, testCase.updateCols) },
+			PanicMatches, testCase.expectedMessage, comment,
+		)
+
+		/**
+		 * The error is captured by handler with caller of upsert
+		 */
+		var err error
+		capturingCtrl := *testedCtrl
+		capturingCtrl.panicHandlers = nil
+		capturingCtrl.RegisterPanicHandler(NewDbErrorCapture(&err))
+
+		c.Assert(capturingCtrl.Upsert(testCase.table, testCase.insertCols, testCase.updateCols), IsNil, comment)
+		c.Assert(err, ErrorMatches, "(?s).*rdb_upsert.go:[0-9]+:"+testCase.expectedMessage, comment)
+		// :~)
+	}
+}
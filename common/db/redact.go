@@ -0,0 +1,26 @@
+package db
+
+// The redactor of arguments of SQL, which is used before any logging or building of message of panic
+//
+// The arguments sent to driver are not affected by this function.
+//
+// The nil value means the arguments are shown as they are.
+var ArgRedactor func(args []interface{}) []interface{}
+
+// Replaces every argument with "***"
+func RedactAll(args []interface{}) []interface{} {
+	redactedArgs := make([]interface{}, len(args))
+	for i := range redactedArgs {
+		redactedArgs[i] = "***"
+	}
+
+	return redactedArgs
+}
+
+func redactArgs(args []interface{}) []interface{} {
+	if ArgRedactor == nil {
+		return args
+	}
+
+	return ArgRedactor(args)
+}
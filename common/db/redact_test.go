@@ -0,0 +1,62 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"os"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestRedactSuite struct{}
+
+var _ = Suite(&TestRedactSuite{})
+
+func (suite *TestRedactSuite) SetUpTest(c *C) {
+	ArgRedactor = RedactAll
+}
+func (suite *TestRedactSuite) TearDownTest(c *C) {
+	ArgRedactor = nil
+}
+
+// Tests the redaction of arguments in message of panic
+func (suite *TestRedactSuite) TestRedactionOfPanic(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedFunc := func() {
+		testedCtrl.QueryForRows(
+			RowsCallbackFunc(func(rows *sql.Rows) IterateControl { return IterateContinue }),
+			"SELECT * FROM no_such_user WHERE password = ?", "my-secret",
+		)
+	}
+
+	c.Assert(testedFunc, PanicMatches, `.*Params: \[\]interface \{\}\{"\*\*\*"\}.*`)
+}
+
+// Tests the redaction of arguments in logging of slow query
+func (suite *TestRedactSuite) TestRedactionOfLog(c *C) {
+	var sentArgs []driver.NamedValue
+	testedCtrl := newFakeDbController(&fakeDriverDb{
+		execFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Result, error) {
+			sentArgs = args
+			time.Sleep(2 * time.Millisecond)
+			return driver.RowsAffected(1), nil
+		},
+	})
+	defer testedCtrl.Release()
+
+	logOutput := &bytes.Buffer{}
+	log.SetOutput(logOutput)
+	defer log.SetOutput(os.Stderr)
+
+	testedCtrl.SetSlowQueryThreshold(time.Millisecond)
+	testedCtrl.Exec("UPDATE owl_user SET password = ?", "my-secret")
+
+	c.Assert(logOutput.String(), Matches, `(?s).*Params: \[\]interface \{\}\{"\*\*\*"\}.*`)
+	c.Assert(logOutput.String(), Not(Matches), "(?s).*my-secret.*")
+	c.Assert(sentArgs[0].Value, Equals, "my-secret")
+}
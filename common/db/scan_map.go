@@ -0,0 +1,119 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// Scans the values of current row into a map of column name to value
+//
+// The []byte values are converted to string for readability, and NULL is nil.
+// The duplicated names of columns would keep the value of latter one.
+func (rowsExt *RowsExt) ScanMap() map[string]interface{} {
+	columns := rowsExt.Columns()
+	values := rowsExt.scanValues()
+
+	result := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if bytesValue, ok := values[i].([]byte); ok {
+			result[column] = string(bytesValue)
+			continue
+		}
+
+		result[column] = values[i]
+	}
+
+	return result
+}
+
+// Gets the zero-based index of columns by their names
+//
+// The duplicated names of columns would keep the index of latter one.
+//
+// While the rows are iterated by the controller(e.g. QueryForRows()), the index is resolved once for all of the rows,
+// so the returned map is shared and should not be modified.
+func (rowsExt *RowsExt) ColumnIndex() map[string]int {
+	tracked, ok := trackedColumnIndexes.Load((*sql.Rows)(rowsExt))
+	if !ok {
+		return toColumnIndex(rowsExt.Columns())
+	}
+
+	lazyIndex := tracked.(*lazyColumnIndex)
+	if lazyIndex.index == nil {
+		lazyIndex.index = toColumnIndex(rowsExt.Columns())
+	}
+
+	return lazyIndex.index
+}
+
+// The indexes of columns for rows being iterated by the controller, which are resolved at the first use
+var trackedColumnIndexes sync.Map
+
+type lazyColumnIndex struct {
+	index map[string]int
+}
+
+// Tracks the index of columns for the rows, the returned function should be deferred to release it
+func trackColumnIndex(rows *sql.Rows) func() {
+	trackedColumnIndexes.Store(rows, &lazyColumnIndex{})
+	return func() {
+		trackedColumnIndexes.Delete(rows)
+	}
+}
+
+// Scans the values of named columns into the pointers of "dest", regardless of the positions of columns
+//
+//	var id int
+//	var name string
+//	rowsExt.ScanByName(map[string]interface{}{
+//		"ag_id": &id,
+//		"ag_name": &name,
+//	})
+//
+// The columns not in "dest" are discarded. The panic is raised if a name in "dest" is not in the columns.
+func (rowsExt *RowsExt) ScanByName(dest map[string]interface{}) {
+	columns := rowsExt.Columns()
+	index := rowsExt.ColumnIndex()
+
+	scannedDest := make([]interface{}, len(columns))
+	for i := range scannedDest {
+		scannedDest[i] = new(interface{})
+	}
+	for name, pointer := range dest {
+		i, ok := index[name]
+		if !ok {
+			PanicIfError(utils.BuildErrorWithCaller(
+				fmt.Errorf("Column is not found for scanning by name: %s", name),
+			))
+		}
+
+		scannedDest[i] = pointer
+	}
+
+	rowsExt.Scan(scannedDest...)
+}
+
+// Scans the values of current row as they are given by driver
+func (rowsExt *RowsExt) scanValues() []interface{} {
+	values := make([]interface{}, len(rowsExt.Columns()))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	rowsExt.Scan(dest...)
+
+	return values
+}
+
+func toColumnIndex(columns []string) map[string]int {
+	index := make(map[string]int, len(columns))
+	for i, column := range columns {
+		index[column] = i
+	}
+
+	return index
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"database/sql"
+	"reflect"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestScanMapSuite struct{}
+
+var _ = Suite(&TestScanMapSuite{})
+
+// Tests the scanning of row into map
+func (suite *TestScanMapSuite) TestScanMap(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.ExecQueriesInTx(
+		"CREATE TABLE diag_m01(dm_id INT PRIMARY KEY, dm_name VARCHAR(32), dm_data BLOB, dm_memo VARCHAR(32))",
+		"INSERT INTO diag_m01 VALUES(1, 'agent-1', X'616263', NULL)",
+	)
+
+	var testedResult map[string]interface{}
+	testedCtrl.QueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			testedResult = ToRowsExt(rows).ScanMap()
+			return IterateContinue
+		}),
+		"SELECT dm_id, dm_name, dm_data, dm_memo, dm_id * 2 AS double_id FROM diag_m01",
+	)
+
+	c.Assert(testedResult, DeepEquals, map[string]interface{}{
+		"dm_id":     int64(1),
+		"dm_name":   "agent-1",
+		"dm_data":   "abc",
+		"dm_memo":   nil,
+		"double_id": int64(2),
+	})
+}
+
+// Tests the scanning by names of columns in different orders
+func (suite *TestScanMapSuite) TestScanByName(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.ExecQueriesInTx(
+		"CREATE TABLE diag_m02(dm_id INT PRIMARY KEY, dm_name VARCHAR(32), dm_memo VARCHAR(32))",
+		"INSERT INTO diag_m02 VALUES(1, 'agent-1', 'memo-1')",
+	)
+
+	testCases := []*struct {
+		sql           string
+		expectedIndex map[string]int
+	}{
+		{"SELECT dm_id, dm_name, dm_memo FROM diag_m02", map[string]int{"dm_id": 0, "dm_name": 1, "dm_memo": 2}},
+		{"SELECT dm_memo, dm_name, dm_id FROM diag_m02", map[string]int{"dm_memo": 0, "dm_name": 1, "dm_id": 2}},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		var testedIndex map[string]int
+		var id int
+		var name string
+		testedCtrl.QueryForRows(
+			RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+				rowsExt := ToRowsExt(rows)
+				testedIndex = rowsExt.ColumnIndex()
+				rowsExt.ScanByName(map[string]interface{}{
+					"dm_id":   &id,
+					"dm_name": &name,
+				})
+				return IterateContinue
+			}),
+			testCase.sql,
+		)
+
+		c.Assert(testedIndex, DeepEquals, testCase.expectedIndex, comment)
+		c.Assert(id, Equals, 1, comment)
+		c.Assert(name, Equals, "agent-1", comment)
+	}
+
+	c.Assert(
+		func() {
+			testedCtrl.QueryForRows(
+				RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+					var memo string
+					ToRowsExt(rows).ScanByName(map[string]interface{}{"no_such_column": &memo})
+					return IterateContinue
+				}),
+				"SELECT dm_id FROM diag_m02",
+			)
+		},
+		PanicMatches, ".*Column is not found.*no_such_column.*",
+	)
+}
+
+// Tests the index of columns is resolved once for all of the rows
+func (suite *TestScanMapSuite) TestColumnIndexOfRows(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.ExecQueriesInTx(
+		"CREATE TABLE diag_m03(dm_id INT PRIMARY KEY, dm_name VARCHAR(32))",
+		"INSERT INTO diag_m03 VALUES(1, 'agent-1'), (2, 'agent-2'), (3, 'agent-3')",
+	)
+
+	var indexes []map[string]int
+	var names []string
+	testedCtrl.QueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			rowsExt := ToRowsExt(rows)
+			indexes = append(indexes, rowsExt.ColumnIndex())
+
+			var name string
+			rowsExt.ScanByName(map[string]interface{}{"dm_name": &name})
+			names = append(names, name)
+			return IterateContinue
+		}),
+		"SELECT dm_id, dm_name FROM diag_m03 ORDER BY dm_id",
+	)
+
+	c.Assert(names, DeepEquals, []string{"agent-1", "agent-2", "agent-3"})
+	c.Assert(indexes, HasLen, 3)
+	for i := 1; i < len(indexes); i++ {
+		c.Assert(reflect.ValueOf(indexes[i]).Pointer(), Equals, reflect.ValueOf(indexes[0]).Pointer(), Commentf("Row: %d", i+1))
+	}
+
+	trackedCount := 0
+	trackedColumnIndexes.Range(func(key, value interface{}) bool {
+		trackedCount++
+		return true
+	})
+	c.Assert(trackedCount, Equals, 0)
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Scans the values of row into pointers, the NULL value is scanned as zero value of the destination
+//
+// The supported types of destination are: *string, *bool, *int, *int8, *int16, *int32, *int64,
+// *uint, *uint8, *uint16, *uint32, *uint64, *float32, *float64, and *time.Time.
+// Other types(e.g. *sql.NullString) are scanned as it is.
+func (rowExt *RowExt) ScanNullable(dest ...interface{}) {
+	nullableDest, assign := toNullableDest(dest)
+
+	rowExt.Scan(nullableDest...)
+	assign()
+}
+
+// Scans the values of current row into pointers, the NULL value is scanned as zero value of the destination
+//
+// See RowExt.ScanNullable() for supported types.
+func (rowsExt *RowsExt) ScanNullable(dest ...interface{}) {
+	nullableDest, assign := toNullableDest(dest)
+
+	rowsExt.Scan(nullableDest...)
+	assign()
+}
+
+// Wraps the pointers of supported types by sql.Null[T],
+// the returned function assigns the scanned values to the original pointers.
+func toNullableDest(dest []interface{}) ([]interface{}, func()) {
+	nullableDest := make([]interface{}, len(dest))
+	assigns := make([]func(), 0, len(dest))
+
+	for i, d := range dest {
+		var assign func()
+
+		switch p := d.(type) {
+		case *string:
+			nullableDest[i], assign = nullableOf(p)
+		case *bool:
+			nullableDest[i], assign = nullableOf(p)
+		case *int:
+			nullableDest[i], assign = nullableOf(p)
+		case *int8:
+			nullableDest[i], assign = nullableOf(p)
+		case *int16:
+			nullableDest[i], assign = nullableOf(p)
+		case *int32:
+			nullableDest[i], assign = nullableOf(p)
+		case *int64:
+			nullableDest[i], assign = nullableOf(p)
+		case *uint:
+			nullableDest[i], assign = nullableOf(p)
+		case *uint8:
+			nullableDest[i], assign = nullableOf(p)
+		case *uint16:
+			nullableDest[i], assign = nullableOf(p)
+		case *uint32:
+			nullableDest[i], assign = nullableOf(p)
+		case *uint64:
+			nullableDest[i], assign = nullableOf(p)
+		case *float32:
+			nullableDest[i], assign = nullableOf(p)
+		case *float64:
+			nullableDest[i], assign = nullableOf(p)
+		case *time.Time:
+			nullableDest[i], assign = nullableOf(p)
+		default:
+			nullableDest[i] = d
+			continue
+		}
+
+		assigns = append(assigns, assign)
+	}
+
+	return nullableDest, func() {
+		for _, assign := range assigns {
+			assign()
+		}
+	}
+}
+
+func nullableOf[T any](dest *T) (interface{}, func()) {
+	nullValue := new(sql.Null[T])
+	return nullValue, func() { *dest = nullValue.V }
+}
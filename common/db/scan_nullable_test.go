@@ -0,0 +1,119 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestScanNullableSuite struct{}
+
+var _ = Suite(&TestScanNullableSuite{})
+
+type sampleNullableRow struct {
+	StringValue  string
+	BoolValue    bool
+	IntValue     int
+	Int32Value   int32
+	Int64Value   int64
+	Uint8Value   uint8
+	Uint64Value  uint64
+	Float32Value float32
+	Float64Value float64
+	TimeValue    time.Time
+}
+
+func (r *sampleNullableRow) dest() []interface{} {
+	return []interface{}{
+		&r.StringValue, &r.BoolValue, &r.IntValue, &r.Int32Value, &r.Int64Value,
+		&r.Uint8Value, &r.Uint64Value, &r.Float32Value, &r.Float64Value, &r.TimeValue,
+	}
+}
+
+// Tests the scanning of NULL and non-NULL values for supported types
+func (suite *TestScanNullableSuite) TestScanNullable(c *C) {
+	sampleTime := time.Date(2017, 3, 8, 10, 20, 30, 0, time.UTC)
+
+	testCases := []*struct {
+		values   []driver.Value
+		expected sampleNullableRow
+	}{
+		{
+			[]driver.Value{[]byte("agent-1"), true, int64(-1), int64(32), int64(64), int64(8), int64(640), 1.5, 2.5, sampleTime},
+			sampleNullableRow{"agent-1", true, -1, 32, 64, 8, 640, 1.5, 2.5, sampleTime},
+		},
+		{
+			[]driver.Value{nil, nil, nil, nil, nil, nil, nil, nil, nil, nil},
+			sampleNullableRow{},
+		},
+	}
+
+	for i, testCase := range testCases {
+		comment := Commentf("Test Case: %d", i+1)
+
+		testedCtrl := newFakeDbController(&fakeDriverDb{
+			queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+				return &fakeRows{
+					columns: []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7", "c8", "c9", "c10"},
+					values:  [][]driver.Value{testCase.values},
+				}, nil
+			},
+		})
+
+		/**
+		 * Scans by RowExt, the destination has non-zero values before scanning
+		 */
+		rowResult := sampleNullableRow{StringValue: "old", IntValue: 99, TimeValue: time.Now()}
+		testedCtrl.QueryForRow(
+			RowCallbackFunc(func(row *sql.Row) {
+				ToRowExt(row).ScanNullable(rowResult.dest()...)
+			}),
+			"SELECT * FROM sample_nullable",
+		)
+		c.Assert(rowResult, DeepEquals, testCase.expected, comment)
+		// :~)
+
+		/**
+		 * Scans by RowsExt
+		 */
+		var rowsResult sampleNullableRow
+		testedCtrl.QueryForRows(
+			RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+				ToRowsExt(rows).ScanNullable(rowsResult.dest()...)
+				return IterateContinue
+			}),
+			"SELECT * FROM sample_nullable",
+		)
+		c.Assert(rowsResult, DeepEquals, testCase.expected, comment)
+		// :~)
+
+		testedCtrl.Release()
+	}
+}
+
+// Tests the destination of unsupported types, which are scanned as they are
+func (suite *TestScanNullableSuite) TestScanNullableWithOtherTypes(c *C) {
+	testedCtrl := newFakeDbController(&fakeDriverDb{
+		queryFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Rows, error) {
+			return &fakeRows{
+				columns: []string{"c1", "c2"},
+				values:  [][]driver.Value{{nil, int64(10)}},
+			}, nil
+		},
+	})
+	defer testedCtrl.Release()
+
+	var nullString sql.NullString
+	var intValue int
+	testedCtrl.QueryForRow(
+		RowCallbackFunc(func(row *sql.Row) {
+			ToRowExt(row).ScanNullable(&nullString, &intValue)
+		}),
+		"SELECT * FROM sample_nullable",
+	)
+
+	c.Assert(nullString.Valid, Equals, false)
+	c.Assert(intValue, Equals, 10)
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/Cepave/open-falcon-backend/common/utils"
+)
+
+// Scans the values of row into fields of struct, with panic instead of returned error
+//
+// The column is mapped to field by tag of "db":
+//
+//	type Car struct {
+//		Id int `db:"c_id"`
+//		// Mapped to column "owner_name"
+//		OwnerName string
+//		// Ignored
+//		Memo string `db:"-"`
+//	}
+//
+// If there is no tag on the field, the column name is the snake case of field name.
+//
+// The exported fields of embedded struct are mapped as fields of the outer struct.
+//
+// The column which has no matching field would be skipped silently.
+func (rowsExt *RowsExt) ScanStruct(dest interface{}) {
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Struct {
+		PanicIfError(utils.BuildErrorWithCaller(
+			fmt.Errorf("Need pointer to struct for scanning. Got: %T", dest),
+		))
+	}
+
+	fields := make(map[string]reflect.Value)
+	collectStructFields(destValue.Elem(), fields)
+
+	columns := rowsExt.Columns()
+	scanDest := make([]interface{}, len(columns))
+	for i, column := range columns {
+		field, ok := fields[strings.ToLower(column)]
+		if !ok {
+			scanDest[i] = new(sql.RawBytes)
+			continue
+		}
+
+		scanDest[i] = field.Addr().Interface()
+	}
+
+	err := ((*sql.Rows)(rowsExt)).Scan(scanDest...)
+	PanicIfError(utils.BuildErrorWithCaller(err))
+}
+
+// Fields of outer struct take precedence over the ones of embedded struct
+func collectStructFields(structValue reflect.Value, fields map[string]reflect.Value) {
+	structType := structValue.Type()
+	embeddedValues := make([]reflect.Value, 0)
+
+	for i := 0; i < structType.NumField(); i++ {
+		fieldType := structType.Field(i)
+		fieldValue := structValue.Field(i)
+
+		tag := fieldType.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+
+		if fieldType.Anonymous && tag == "" {
+			embeddedValue := fieldValue
+			if embeddedValue.Kind() == reflect.Ptr {
+				if embeddedValue.IsNil() && embeddedValue.CanSet() {
+					embeddedValue.Set(reflect.New(embeddedValue.Type().Elem()))
+				}
+				embeddedValue = embeddedValue.Elem()
+			}
+
+			if embeddedValue.Kind() == reflect.Struct {
+				embeddedValues = append(embeddedValues, embeddedValue)
+				continue
+			}
+		}
+
+		if fieldType.PkgPath != "" {
+			continue
+		}
+
+		columnName := tag
+		if columnName == "" {
+			columnName = toSnakeCase(fieldType.Name)
+		}
+
+		columnName = strings.ToLower(columnName)
+		if _, ok := fields[columnName]; !ok {
+			fields[columnName] = fieldValue
+		}
+	}
+
+	for _, embeddedValue := range embeddedValues {
+		embeddedFields := make(map[string]reflect.Value)
+		collectStructFields(embeddedValue, embeddedFields)
+
+		for columnName, fieldValue := range embeddedFields {
+			if _, ok := fields[columnName]; !ok {
+				fields[columnName] = fieldValue
+			}
+		}
+	}
+}
+
+// Converts "OwnerName" to "owner_name", "HTTPCode" to "http_code"
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	var result []rune
+
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				result = append(result, '_')
+			}
+			result = append(result, unicode.ToLower(r))
+			continue
+		}
+
+		result = append(result, r)
+	}
+
+	return string(result)
+}
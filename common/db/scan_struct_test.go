@@ -0,0 +1,92 @@
+package db
+
+import (
+	"database/sql"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestScanStructSuite struct{}
+
+var _ = Suite(&TestScanStructSuite{})
+
+type sampleAudit struct {
+	CreatedAt string
+	UpdatedAt string `db:"updated_time"`
+}
+
+type sampleOwner struct {
+	sampleAudit
+
+	Id        int `db:"ow_id"`
+	OwnerName string
+	Memo      string `db:"-"`
+}
+
+// Tests the scanning of row into struct
+func (suite *TestScanStructSuite) TestScanStruct(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedCtrl.Exec(`
+		CREATE TABLE owner_s01(
+			ow_id INT PRIMARY KEY, owner_name VARCHAR(64),
+			created_at VARCHAR(32), updated_time VARCHAR(32),
+			ow_extra INT, memo VARCHAR(16)
+		)
+	`)
+	testedCtrl.Exec(`
+		INSERT INTO owner_s01
+		VALUES(1, 'Bob', '2017-01-01', '2017-02-01', 99, 'memo-1')
+	`)
+
+	testedOwner := &sampleOwner{}
+	testedCtrl.QueryForRows(
+		RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+			ToRowsExt(rows).ScanStruct(testedOwner)
+			return IterateContinue
+		}),
+		"SELECT * FROM owner_s01",
+	)
+
+	c.Assert(testedOwner, DeepEquals, &sampleOwner{
+		sampleAudit: sampleAudit{"2017-01-01", "2017-02-01"},
+		Id:          1,
+		OwnerName:   "Bob",
+	})
+}
+
+// Tests the scanning with mismatched type
+func (suite *TestScanStructSuite) TestScanStructWithMismatchedType(c *C) {
+	testedCtrl := buildSampleDbController(c)
+	defer testedCtrl.Release()
+
+	testedFunc := func() {
+		testedCtrl.QueryForRows(
+			RowsCallbackFunc(func(rows *sql.Rows) IterateControl {
+				ToRowsExt(rows).ScanStruct(&sampleOwner{})
+				return IterateContinue
+			}),
+			"SELECT 'not-a-number' AS ow_id",
+		)
+	}
+
+	c.Assert(testedFunc, PanicMatches, ".*converting.*")
+}
+
+// Tests the conversion of field name to snake case
+func (suite *TestScanStructSuite) TestToSnakeCase(c *C) {
+	testCases := []*struct {
+		sample   string
+		expected string
+	}{
+		{"Id", "id"},
+		{"OwnerName", "owner_name"},
+		{"HTTPCode", "http_code"},
+		{"NqmAgentID", "nqm_agent_id"},
+	}
+
+	for i, testCase := range testCases {
+		c.Assert(toSnakeCase(testCase.sample), Equals, testCase.expected, Commentf("Test Case: %d", i+1))
+	}
+}
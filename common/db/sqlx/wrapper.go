@@ -174,6 +174,8 @@ func (ctrl *DbController) InTx(txCallback TxCallback) {
 		db.PanicIfError(tx.Commit())
 	case db.TxRollback:
 		db.PanicIfError(tx.Rollback())
+	case db.TxAbort:
+		db.PanicIfError(utils.BuildErrorWithCallerInfo(db.ErrTxAborted, callerInfo))
 	}
 }
 
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// LRU cache of prepared statements, which is keyed by text of SQL
+type stmtCache struct {
+	lock sync.Mutex
+
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type stmtCacheEntry struct {
+	query string
+	stmt  *sql.Stmt
+	// Set to 1 after the statement is closed by evicting, accessed by sync/atomic
+	closed int32
+}
+
+func (entry *stmtCacheEntry) isClosed() bool {
+	return atomic.LoadInt32(&entry.closed) == 1
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Gets the cached statement or prepares a new one
+//
+// The preparing is performed without holding the lock, so the queries of other statements are not blocked.
+// If the same query is prepared by another goroutine meanwhile, the duplicated statement is closed.
+func (cache *stmtCache) get(ctx context.Context, db *sql.DB, query string) (*stmtCacheEntry, error) {
+	if entry := cache.lookup(query); entry != nil {
+		return entry, nil
+	}
+
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if element, ok := cache.entries[query]; ok {
+		stmt.Close()
+
+		cache.lru.MoveToFront(element)
+		return element.Value.(*stmtCacheEntry), nil
+	}
+
+	entry := &stmtCacheEntry{query: query, stmt: stmt}
+	cache.entries[query] = cache.lru.PushFront(entry)
+
+	for cache.lru.Len() > cache.size {
+		cache.removeElement(cache.lru.Back())
+	}
+
+	return entry, nil
+}
+
+func (cache *stmtCache) lookup(query string) *stmtCacheEntry {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	element, ok := cache.entries[query]
+	if !ok {
+		return nil
+	}
+
+	cache.lru.MoveToFront(element)
+	return element.Value.(*stmtCacheEntry)
+}
+
+// Evicts the entry of statement, the statement gets closed
+//
+// Nothing is done if the entry has been replaced by another one of the same query.
+func (cache *stmtCache) evict(entry *stmtCacheEntry) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if element, ok := cache.entries[entry.query]; ok && element.Value.(*stmtCacheEntry) == entry {
+		cache.removeElement(element)
+	}
+}
+
+// Closes all of the cached statements
+func (cache *stmtCache) clear() {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	for cache.lru.Len() > 0 {
+		cache.removeElement(cache.lru.Back())
+	}
+}
+
+func (cache *stmtCache) removeElement(element *list.Element) {
+	entry := cache.lru.Remove(element).(*stmtCacheEntry)
+	delete(cache.entries, entry.query)
+
+	atomic.StoreInt32(&entry.closed, 1)
+	entry.stmt.Close()
+}
+
+// Sets the size of cache for prepared statements, the non-positive value disables the cache.
+//
+// While the cache is enabled, Exec() and query methods(including theirs variants of context) use
+// prepared statement cached by the text of SQL.
+//
+// If the executing of statement fails because of bad connection or closed statement,
+// the statement is evicted and prepared again.
+func (dbController *DbController) SetStatementCacheSize(n int) {
+	if dbController.stmtCache != nil {
+		dbController.stmtCache.clear()
+		dbController.stmtCache = nil
+	}
+
+	if n > 0 {
+		dbController.stmtCache = newStmtCache(n)
+	}
+}
+
+// Executes the function with cached statement, the function is retried once if the statement is invalid
+func (dbController *DbController) withCachedStmt(
+	ctx context.Context, db *sql.DB, query string,
+	stmtFunc func(stmt *sql.Stmt) error,
+) error {
+	cache := dbController.stmtCache
+
+	entry, err := cache.get(ctx, db, query)
+	if err != nil {
+		return err
+	}
+
+	err = stmtFunc(entry.stmt)
+	if !isInvalidStmtError(entry, err) {
+		return err
+	}
+
+	cache.evict(entry)
+	entry, err = cache.get(ctx, db, query)
+	if err != nil {
+		return err
+	}
+
+	return stmtFunc(entry.stmt)
+}
+
+// The statement is invalid if the connection is bad, or it has been closed by evicting while it is being used
+func isInvalidStmtError(entry *stmtCacheEntry, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || entry.isClosed()
+}
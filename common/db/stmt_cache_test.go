@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type TestStmtCacheSuite struct{}
+
+var _ = Suite(&TestStmtCacheSuite{})
+
+// Tests the preparing of statement only once for same SQL
+func (suite *TestStmtCacheSuite) TestSetStatementCacheSize(c *C) {
+	fakeDb := &fakeDriverDb{}
+	testedCtrl := newFakeDbController(fakeDb)
+	defer testedCtrl.Release()
+
+	testedCtrl.SetStatementCacheSize(2)
+
+	for i := 0; i < 3; i++ {
+		testedCtrl.Exec("UPDATE nqm_agent SET ag_status = ?", i)
+		testedCtrl.QueryForRows(
+			RowsCallbackFunc(func(rows *sql.Rows) IterateControl { return IterateContinue }),
+			"SELECT * FROM nqm_agent WHERE ag_id = ?", i,
+		)
+	}
+
+	c.Assert(fakeDb.prepared("UPDATE nqm_agent SET ag_status = ?"), Equals, 1)
+	c.Assert(fakeDb.prepared("SELECT * FROM nqm_agent WHERE ag_id = ?"), Equals, 1)
+	c.Assert(fakeDb.executed(), HasLen, 6)
+
+	/**
+	 * Evicts the least recently used statement
+	 */
+	testedCtrl.Exec("DELETE FROM nqm_agent")
+	testedCtrl.Exec("UPDATE nqm_agent SET ag_status = ?", 3)
+	c.Assert(fakeDb.prepared("UPDATE nqm_agent SET ag_status = ?"), Equals, 2)
+	// :~)
+}
+
+// Tests the re-preparing of statement if the connection is bad
+func (suite *TestStmtCacheSuite) TestInvalidatedStatement(c *C) {
+	numberOfExec := 0
+	fakeDb := &fakeDriverDb{
+		execFunc: func(conn *fakeConn, query string, args []driver.NamedValue) (driver.Result, error) {
+			numberOfExec++
+			if numberOfExec == 2 {
+				return nil, driver.ErrBadConn
+			}
+
+			return driver.RowsAffected(1), nil
+		},
+	}
+	testedCtrl := newFakeDbController(fakeDb)
+	defer testedCtrl.Release()
+
+	testedCtrl.SetStatementCacheSize(4)
+
+	testedCtrl.Exec("DELETE FROM nqm_agent")
+	testedCtrl.Exec("DELETE FROM nqm_agent")
+
+	c.Assert(fakeDb.prepared("DELETE FROM nqm_agent"), Equals, 2)
+}
+
+// Tests the preparing of statement doesn't block the cached statements
+func (suite *TestStmtCacheSuite) TestPrepareWithoutLock(c *C) {
+	preparing := make(chan struct{})
+	release := make(chan struct{})
+	fakeDb := &fakeDriverDb{
+		prepareFunc: func(query string) {
+			if query == "DELETE FROM nqm_log" {
+				close(preparing)
+				<-release
+			}
+		},
+	}
+	testedCtrl := newFakeDbController(fakeDb)
+	defer testedCtrl.Release()
+
+	testedCtrl.SetStatementCacheSize(4)
+	testedCtrl.Exec("DELETE FROM nqm_agent")
+
+	slowDone := make(chan struct{})
+	go func() {
+		defer close(slowDone)
+		testedCtrl.Exec("DELETE FROM nqm_log")
+	}()
+	<-preparing
+
+	quickDone := make(chan struct{})
+	go func() {
+		defer close(quickDone)
+		testedCtrl.Exec("DELETE FROM nqm_agent")
+	}()
+
+	select {
+	case <-quickDone:
+	case <-time.After(time.Second):
+		c.Errorf("The cached statement is blocked by preparing of another statement")
+	}
+
+	close(release)
+	<-slowDone
+}
+
+// Tests the re-preparing of statement which is closed by evicting while it is being used
+func (suite *TestStmtCacheSuite) TestClosedStatement(c *C) {
+	fakeDb := &fakeDriverDb{}
+	testedCtrl := newFakeDbController(fakeDb)
+	defer testedCtrl.Release()
+
+	testedCtrl.SetStatementCacheSize(4)
+
+	numberOfCalls := 0
+	err := testedCtrl.withCachedStmt(
+		context.Background(), testedCtrl.dbObject, "DELETE FROM nqm_agent",
+		func(stmt *sql.Stmt) error {
+			numberOfCalls++
+			if numberOfCalls == 1 {
+				testedCtrl.stmtCache.clear()
+			}
+
+			_, err := stmt.Exec()
+			return err
+		},
+	)
+
+	c.Assert(err, IsNil)
+	c.Assert(numberOfCalls, Equals, 2)
+	c.Assert(fakeDb.prepared("DELETE FROM nqm_agent"), Equals, 2)
+}
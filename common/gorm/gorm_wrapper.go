@@ -150,6 +150,8 @@ func (self *GormDbExt) InTx(txCallback TxCallback) {
 		self.ConvertError.PanicIfDbError(txGormDb.Commit())
 	case db.TxRollback:
 		self.ConvertError.PanicIfDbError(txGormDb.Rollback())
+	case db.TxAbort:
+		self.ConvertError.PanicIfError(db.ErrTxAborted)
 	}
 }
 
@@ -164,6 +164,11 @@ func (e *StackError) Error() string {
 	return fmt.Sprintf("%s:%d:%v", e.callerInfo.GetFile(), e.callerInfo.Line, e.cause)
 }
 
+// Gets the cause of this error, which could be used by errors.Is() or errors.As()
+func (e *StackError) Unwrap() error {
+	return e.cause
+}
+
 func DeferCatchPanicWithCaller() func() {
 	callerInfo := gr.GetCallerInfoWithDepth(1)
 
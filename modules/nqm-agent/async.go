@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// The error of enqueuing after the pusher is shut down
+	ErrAsyncPusherClosed = errors.New("Async pusher has been shut down")
+	// The error of enqueuing while the queue is full and PushQueueFullBehavior is "drop"
+	ErrAsyncQueueFull = errors.New("Queue of async pusher is full")
+)
+
+type asyncPushItem struct {
+	params []ParamToAgent
+	util   string
+}
+
+// Pushes params by a pool of workers
+//
+// The producers call Enqueue() and return immediately, the workers push the params by PushOrLog().
+type AsyncPusher struct {
+	queue chan asyncPushItem
+	wg    sync.WaitGroup
+
+	// Protects the queue from being closed while enqueuing
+	lock    sync.RWMutex
+	started bool
+	closed  bool
+
+	// Closed by Shutdown() to wake up the producers blocked by the full queue
+	done      chan struct{}
+	closeOnce sync.Once
+
+	dropped uint64
+}
+
+func NewAsyncPusher() *AsyncPusher {
+	return &AsyncPusher{done: make(chan struct{})}
+}
+
+// Starts the workers with the buffer size of queue, this method should be called only once
+func (p *AsyncPusher) Start(workers, bufferSize int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.started {
+		panic("Async pusher has been started")
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+	if bufferSize < 0 {
+		bufferSize = 0
+	}
+
+	p.queue = make(chan asyncPushItem, bufferSize)
+	p.started = true
+
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+}
+
+func (p *AsyncPusher) work() {
+	defer p.wg.Done()
+
+	for item := range p.queue {
+		PushOrLog(item.params, item.util)
+	}
+}
+
+// Enqueues the params to be pushed
+//
+// If the queue is full, this method is blocked or the params are dropped,
+// which depends on Config().Agent.PushQueueFullBehavior.
+// The blocked enqueuing returns ErrAsyncPusherClosed once Shutdown() is called.
+func (p *AsyncPusher) Enqueue(params []ParamToAgent, util string) error {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if !p.started || p.closed {
+		return ErrAsyncPusherClosed
+	}
+	select {
+	case <-p.done:
+		return ErrAsyncPusherClosed
+	default:
+	}
+
+	item := asyncPushItem{params, util}
+	if Config().Agent.PushQueueFullBehavior != OverflowDrop {
+		select {
+		case p.queue <- item:
+			return nil
+		case <-p.done:
+			return ErrAsyncPusherClosed
+		}
+	}
+
+	select {
+	case p.queue <- item:
+		return nil
+	default:
+		atomic.AddUint64(&p.dropped, 1)
+		log.Warnln("[", util, "] Queue of async pusher is full, dropped", len(params), "param(s)")
+		return ErrAsyncQueueFull
+	}
+}
+
+// Gets the number of dropped enqueuing because of full queue
+func (p *AsyncPusher) Dropped() uint64 {
+	return atomic.LoadUint64(&p.dropped)
+}
+
+// Stops accepting params and waits for the queued params to be pushed
+//
+// The error of context is returned if the context is done before the queue is drained.
+func (p *AsyncPusher) Shutdown(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		if p.done != nil {
+			close(p.done)
+		}
+	})
+
+	drained := make(chan struct{})
+	go func() {
+		// The lock is released by the producers woken up by closing of "done"
+		p.lock.Lock()
+		if p.started && !p.closed {
+			p.closed = true
+			close(p.queue)
+		}
+		p.lock.Unlock()
+
+		p.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
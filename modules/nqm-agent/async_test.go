@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAsyncPusher(t *testing.T) {
+	var numberOfPosts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numberOfPosts, 1)
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL})
+
+	pusher := NewAsyncPusher()
+	pusher.Start(3, 10)
+
+	for i := 0; i < 20; i++ {
+		if err := pusher.Enqueue(newSampleParams(1), "fping"); err != nil {
+			t.Fatalf("Enqueue has error: %v", err)
+		}
+	}
+
+	if err := pusher.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown has error: %v", err)
+	}
+	if n := atomic.LoadInt32(&numberOfPosts); n != 20 {
+		t.Errorf("Expected 20 POSTs after draining, got: %d", n)
+	}
+	if err := pusher.Enqueue(newSampleParams(1), "fping"); err != ErrAsyncPusherClosed {
+		t.Errorf("Expected ErrAsyncPusherClosed after shutdown, got: %v", err)
+	}
+}
+
+func TestAsyncPusherDropOnFull(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushQueueFullBehavior: OverflowDrop})
+
+	pusher := NewAsyncPusher()
+	pusher.Start(1, 1)
+
+	// The first one is taken by the worker, the second one is buffered
+	pusher.Enqueue(newSampleParams(1), "fping")
+	time.Sleep(50 * time.Millisecond)
+	pusher.Enqueue(newSampleParams(1), "fping")
+
+	if err := pusher.Enqueue(newSampleParams(1), "fping"); err != ErrAsyncQueueFull {
+		t.Errorf("Expected ErrAsyncQueueFull, got: %v", err)
+	}
+	if dropped := pusher.Dropped(); dropped != 1 {
+		t.Errorf("Expected 1 dropped, got: %d", dropped)
+	}
+
+	close(release)
+	pusher.Shutdown(context.Background())
+}
+
+func TestAsyncPusherShutdownWithPending(t *testing.T) {
+	release := make(chan struct{})
+	var numberOfPosts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		atomic.AddInt32(&numberOfPosts, 1)
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL})
+
+	pusher := NewAsyncPusher()
+	pusher.Start(1, 5)
+	for i := 0; i < 3; i++ {
+		pusher.Enqueue(newSampleParams(1), "fping")
+	}
+
+	// The pending items are not drained before the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := pusher.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded, got: %v", err)
+	}
+
+	close(release)
+	if err := pusher.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown has error: %v", err)
+	}
+	if n := atomic.LoadInt32(&numberOfPosts); n != 3 {
+		t.Errorf("Expected pending items to be pushed, got: %d POST(s)", n)
+	}
+}
+
+func TestAsyncPusherShutdownWithBlockedProducer(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushQueueFullBehavior: OverflowBlock})
+
+	pusher := NewAsyncPusher()
+	pusher.Start(1, 1)
+
+	// The first one is taken by the worker, the second one is buffered
+	pusher.Enqueue(newSampleParams(1), "fping")
+	time.Sleep(50 * time.Millisecond)
+	pusher.Enqueue(newSampleParams(1), "fping")
+
+	// The third one is blocked by the full queue
+	blockedErr := make(chan error, 1)
+	go func() {
+		blockedErr <- pusher.Enqueue(newSampleParams(1), "fping")
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	if err := pusher.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded, got: %v", err)
+	}
+	if elapsed := time.Since(startTime); elapsed > time.Second {
+		t.Errorf("Shutdown doesn't respect the deadline, elapsed: %v", elapsed)
+	}
+
+	select {
+	case err := <-blockedErr:
+		if err != ErrAsyncPusherClosed {
+			t.Errorf("Expected ErrAsyncPusherClosed for blocked producer, got: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("The blocked producer is not woken up by shutdown")
+	}
+
+	close(release)
+	if err := pusher.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown has error: %v", err)
+	}
+}
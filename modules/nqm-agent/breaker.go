@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultPushBreakerCooldown = 30 * time.Second
+
+// The error of push which is failed fast by the opened circuit breaker
+var ErrPushCircuitOpen = errors.New("Push has been failed fast by the open circuit breaker")
+
+type CircuitState string
+
+const (
+	// The pushes are performed normally
+	CircuitClosed CircuitState = "closed"
+	// The pushes are failed fast until the cooldown is passed
+	CircuitOpen CircuitState = "open"
+	// One push is performed to probe the recovery of server
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// The circuit breaker opened after consecutive failed pushes
+//
+// The threshold and cooldown are loaded from configuration on every call.
+type circuitBreaker struct {
+	lock sync.Mutex
+
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+var pushBreaker = newCircuitBreaker()
+
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{state: CircuitClosed}
+}
+
+// Gets the current state of the circuit breaker of push
+func PushCircuitState() CircuitState {
+	return pushBreaker.currentState()
+}
+
+func (b *circuitBreaker) currentState() CircuitState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.transit()
+	return b.state
+}
+
+// Opened breaker becomes half-open after the cooldown
+func (b *circuitBreaker) transit() {
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= pushBreakerCooldown() {
+		b.state = CircuitHalfOpen
+		b.probing = false
+	}
+}
+
+// Checks whether or not the push could be performed
+//
+// Only one push is permitted while the breaker is half-open.
+func (b *circuitBreaker) allow() bool {
+	if Config().Agent.PushBreakerThreshold <= 0 {
+		return true
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.transit()
+	switch b.state {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+
+	return true
+}
+
+// Records the result of a permitted push
+func (b *circuitBreaker) record(success bool) {
+	threshold := Config().Agent.PushBreakerThreshold
+	if threshold <= 0 {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if success {
+		if b.state != CircuitClosed {
+			log.Println("[ push ] Circuit breaker is closed")
+		}
+		b.state = CircuitClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= threshold {
+		if b.state != CircuitOpen {
+			log.Warnln("[ push ] Circuit breaker is opened after", b.failures, "consecutive failure(s)")
+		}
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+		b.probing = false
+	}
+}
+
+// Releases a permitted push without recording its result(e.g. the push is cancelled)
+//
+// The breaker keeps its state and permits another probe if it is half-open.
+func (b *circuitBreaker) release() {
+	if Config().Agent.PushBreakerThreshold <= 0 {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state == CircuitHalfOpen {
+		b.probing = false
+	}
+}
+
+type pushAttemptsKey struct{}
+
+// Gives the context which counts the HTTP requests of push(see countPushAttempt()),
+// so the breaker records the result only if the push has reached the server
+func withPushAttempts(ctx context.Context) (context.Context, *int32) {
+	attempts := new(int32)
+	return context.WithValue(ctx, pushAttemptsKey{}, attempts), attempts
+}
+
+func countPushAttempt(ctx context.Context) {
+	if attempts, ok := ctx.Value(pushAttemptsKey{}).(*int32); ok {
+		atomic.AddInt32(attempts, 1)
+	}
+}
+
+func pushBreakerCooldown() time.Duration {
+	cooldown := Config().Agent.PushBreakerCooldown * time.Millisecond
+	if cooldown <= 0 {
+		return defaultPushBreakerCooldown
+	}
+
+	return cooldown
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPushWithCircuitBreaker(t *testing.T) {
+	var healthy int32
+	var numberOfPosts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numberOfPosts, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushBreakerThreshold: 3, PushBreakerCooldown: 100})
+
+	// Drives the breaker open
+	for i := 0; i < 3; i++ {
+		if err := Push(newSampleParams(1), "fping"); err == nil || errors.Is(err, ErrPushCircuitOpen) {
+			t.Fatalf("[%d] Expected error of server, got: %v", i+1, err)
+		}
+	}
+	if state := PushCircuitState(); state != CircuitOpen {
+		t.Fatalf("Expected state: open, got: %s", state)
+	}
+
+	// Fast-fails while the breaker is open
+	if err := Push(newSampleParams(1), "fping"); !errors.Is(err, ErrPushCircuitOpen) {
+		t.Errorf("Expected ErrPushCircuitOpen, got: %v", err)
+	}
+	if n := atomic.LoadInt32(&numberOfPosts); n != 3 {
+		t.Errorf("Expected no POST while the breaker is open, got: %d POST(s)", n)
+	}
+
+	// The failed probe opens the breaker again
+	time.Sleep(150 * time.Millisecond)
+	if state := PushCircuitState(); state != CircuitHalfOpen {
+		t.Fatalf("Expected state: half-open, got: %s", state)
+	}
+	Push(newSampleParams(1), "fping")
+	if state := PushCircuitState(); state != CircuitOpen {
+		t.Fatalf("Expected state: open after failed probe, got: %s", state)
+	}
+
+	// The succeeded probe closes the breaker
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(150 * time.Millisecond)
+	if err := Push(newSampleParams(1), "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+	if state := PushCircuitState(); state != CircuitClosed {
+		t.Errorf("Expected state: closed, got: %s", state)
+	}
+}
+
+func TestCircuitBreakerWithCancelledProbe(t *testing.T) {
+	var healthy int32
+	var numberOfPosts int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&numberOfPosts, 1) == 2 {
+			// Holds the first probe until the push is cancelled
+			close(started)
+			<-release
+			return
+		}
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushBreakerThreshold: 1, PushBreakerCooldown: 100})
+
+	if err := Push(newSampleParams(1), "fping"); err == nil {
+		t.Fatalf("Expected error of server")
+	}
+	if state := PushCircuitState(); state != CircuitOpen {
+		t.Fatalf("Expected state: open, got: %s", state)
+	}
+
+	// The probe is cancelled before the server responds
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(150 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	if err := PushWithContext(ctx, newSampleParams(1), "fping"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected error of cancellation, got: %v", err)
+	}
+	if state := PushCircuitState(); state != CircuitHalfOpen {
+		t.Fatalf("Expected state: half-open after cancelled probe, got: %s", state)
+	}
+
+	// Another probe is permitted
+	if err := Push(newSampleParams(1), "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+	if state := PushCircuitState(); state != CircuitClosed {
+		t.Errorf("Expected state: closed, got: %s", state)
+	}
+	if n := atomic.LoadInt32(&numberOfPosts); n != 3 {
+		t.Errorf("Expected 3 POST(s), got: %d", n)
+	}
+}
+
+func TestCircuitBreakerWithOversizedProbe(t *testing.T) {
+	var healthy int32
+	var numberOfPosts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numberOfPosts, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	body, _ := marshalJSONParams(newSampleParams(1))
+	setPushConfig(&AgentConfig{
+		PushURL: server.URL, PushBreakerThreshold: 1, PushBreakerCooldown: 100,
+		PushMaxBodyBytes: len(body),
+	})
+
+	if err := Push(newSampleParams(1), "fping"); err == nil {
+		t.Fatalf("Expected error of server")
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	// The body rejected locally doesn't close the breaker
+	if err := Push(newSampleParams(10), "fping"); !errors.Is(err, ErrPushBodyTooLarge) {
+		t.Fatalf("Expected ErrPushBodyTooLarge, got: %v", err)
+	}
+	if state := PushCircuitState(); state != CircuitHalfOpen {
+		t.Fatalf("Expected state: half-open after oversized probe, got: %s", state)
+	}
+
+	// Another probe is permitted
+	atomic.StoreInt32(&healthy, 1)
+	if err := Push(newSampleParams(1), "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+	if state := PushCircuitState(); state != CircuitClosed {
+		t.Errorf("Expected state: closed, got: %s", state)
+	}
+	if n := atomic.LoadInt32(&numberOfPosts); n != 2 {
+		t.Errorf("Expected 2 POST(s), got: %d", n)
+	}
+}
@@ -13,8 +13,81 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// The behaviors while the limit of rate or queue is exceeded
+const (
+	// Blocks the caller until the push is available
+	OverflowBlock = "block"
+	// Drops the params
+	OverflowDrop = "drop"
+)
+
+// The configuration of TLS used by push
+type PushTLSConfig struct {
+	// The file of PEM-encoded CA certificates to verify the server
+	CAFile string `json:"caFile"`
+	// The files of PEM-encoded certificate and key of client
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// Whether or not to skip the verification of server's certificate, for testing only
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+}
+
 type AgentConfig struct {
 	PushURL string `json:"pushURL"`
+	// The URLs of targets tried in order, PushURL is used if this is empty
+	PushURLs []string `json:"pushURLs"`
+	// Whether or not to rotate the first tried target of PushURLs for every push
+	PushRoundRobin bool `json:"pushRoundRobin"`
+	// The number of retries after the first failed push
+	PushRetries int `json:"pushRetries"`
+	// The base delay(milliseconds) between retries, which is doubled for every following retry, with random jitter
+	PushRetryInterval time.Duration `json:"pushRetryInterval"`
+	// The maximum delay(milliseconds) between retries, default is 30000
+	PushRetryMaxInterval time.Duration `json:"pushRetryMaxInterval"`
+	// The maximum time(milliseconds) of retrying a push since the first attempt, unlimited if it is non-positive
+	PushDeadline time.Duration `json:"pushDeadline"`
+	// The timeout(milliseconds) of HTTP client for pushing, default is 5000
+	PushTimeout time.Duration `json:"pushTimeout"`
+	// Whether or not to compress the body of push with gzip
+	PushGzip bool `json:"pushGzip"`
+	// The body of push is compressed only if its size(bytes) exceeds the value, every body is compressed if it is non-positive
+	PushGzipMinBytes int `json:"pushGzipMinBytes"`
+	// The format of body for pushing: "json"(default), "msgpack", or the one registered by RegisterPushEncoder()
+	PushFormat string `json:"pushFormat"`
+	// The Content-Type of body for pushing, the one of PushFormat is used if it is empty
+	PushContentType string `json:"pushContentType"`
+	// The path appended to the URLs of targets, e.g. "/v1/push"
+	PushPath string `json:"pushPath"`
+	// The time(milliseconds) of building body for pushing to log a warning, default is 1000
+	PushMarshalWarnTime time.Duration `json:"pushMarshalWarnTime"`
+	// Whether or not to collapse params with identical endpoint, metric, tags and timestamp before pushing
+	PushDedup bool `json:"pushDedup"`
+	// The maximum size(bytes) of body for pushing, the params are dropped if the body is larger, unlimited if it is non-positive
+	PushMaxBodyBytes int `json:"pushMaxBodyBytes"`
+	// The token sent as "Authorization: Bearer <token>", nothing is sent if it is empty
+	PushToken string `json:"pushToken"`
+	// The extra headers of HTTP sent with every push
+	PushHeaders map[string]string `json:"pushHeaders"`
+	// The configuration of TLS for pushing to HTTPS
+	PushTLS *PushTLSConfig `json:"pushTLS"`
+	// The maximum number of pushes per second, the push is unlimited if it is non-positive
+	PushRateLimit float64 `json:"pushRateLimit"`
+	// The behavior while the rate limit is exceeded: "block"(default) or "drop"
+	PushRateLimitBehavior string `json:"pushRateLimitBehavior"`
+	// The number of consecutive failed pushes to open the circuit breaker, the breaker is disabled if it is non-positive
+	PushBreakerThreshold int `json:"pushBreakerThreshold"`
+	// The duration(milliseconds) of fast-failing pushes after the breaker is opened, default is 30000
+	PushBreakerCooldown time.Duration `json:"pushBreakerCooldown"`
+	// The behavior while the queue of async pusher is full: "block"(default) or "drop"
+	PushQueueFullBehavior string `json:"pushQueueFullBehavior"`
+	// The directory to keep params of failed pushes, spooling is disabled if it is empty
+	SpoolDir string `json:"spoolDir"`
+	// The maximum number of spooled files(not the size of them), default is 100
+	SpoolMaxFiles int `json:"spoolMaxFiles"`
+	// The maximum total size(bytes) of spooled files, unlimited if it is non-positive
+	SpoolMaxBytes int64 `json:"spoolMaxBytes"`
+	// The interval(seconds) of replaying spooled files, default is 60
+	SpoolReplayInterval time.Duration `json:"spoolReplayInterval"`
 }
 
 type HbsConfig struct {
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+)
+
+const (
+	PushFormatJSON    = "json"
+	PushFormatMsgpack = "msgpack"
+)
+
+const (
+	jsonContentType    = "application/json; charset=UTF-8"
+	msgpackContentType = "application/x-msgpack"
+)
+
+// The encoder of body for pushing, which gives the encoded body and its Content-Type
+type PushEncoder func(params []ParamToAgent) (body []byte, contentType string, err error)
+
+var (
+	pushEncoders = map[string]PushEncoder{
+		PushFormatJSON:    encodeJSONParams,
+		PushFormatMsgpack: encodeMsgpackParams,
+	}
+	pushEncodersLock sync.RWMutex
+)
+
+// Registers the encoder of the format, which is selected by PushFormat of configuration
+//
+// The encoder of registered format(including the built-in "json" and "msgpack") is replaced.
+// This function should be called before InitPushClient(), which fails if the format of configuration is not registered.
+func RegisterPushEncoder(name string, enc func([]ParamToAgent) ([]byte, string, error)) {
+	if name == "" || enc == nil {
+		panic(fmt.Errorf("Need name and function to register encoder of push. Name: %q", name))
+	}
+
+	pushEncodersLock.Lock()
+	defer pushEncodersLock.Unlock()
+
+	pushEncoders[name] = enc
+}
+
+// Gets the encoder by the format, the JSON encoder is used if the format is empty
+func getPushEncoder(format string) (PushEncoder, error) {
+	if format == "" {
+		format = PushFormatJSON
+	}
+
+	pushEncodersLock.RLock()
+	defer pushEncodersLock.RUnlock()
+
+	if encoder, ok := pushEncoders[format]; ok {
+		return encoder, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported format of push: %q", format)
+}
+
+func encodeJSONParams(params []ParamToAgent) ([]byte, string, error) {
+	body, err := marshalJSONParams(params)
+	return body, jsonContentType, err
+}
+
+func encodeMsgpackParams(params []ParamToAgent) ([]byte, string, error) {
+	body, err := marshalMsgpackParams(params)
+	return body, msgpackContentType, err
+}
+
+func marshalJSONParams(params []ParamToAgent) ([]byte, error) {
+	return json.Marshal(params)
+}
+
+// Encodes the params as msgpack array of maps, the keys of map are the same as the ones of JSON
+func marshalMsgpackParams(params []ParamToAgent) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writeMsgpackArrayHeader(&buf, len(params))
+	for _, p := range params {
+		// Map with 7 entries
+		buf.WriteByte(0x87)
+
+		writeMsgpackString(&buf, "metric")
+		writeMsgpackString(&buf, p.Metric)
+		writeMsgpackString(&buf, "endpoint")
+		writeMsgpackString(&buf, p.Endpoint)
+		writeMsgpackString(&buf, "value")
+		if err := writeMsgpackValue(&buf, p.Value); err != nil {
+			return nil, err
+		}
+		writeMsgpackString(&buf, "counterType")
+		writeMsgpackString(&buf, p.CounterType)
+		writeMsgpackString(&buf, "tags")
+		writeMsgpackString(&buf, p.Tags)
+		writeMsgpackString(&buf, "timestamp")
+		writeMsgpackInt(&buf, p.Timestamp)
+		writeMsgpackString(&buf, "step")
+		writeMsgpackInt(&buf, p.Step)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeMsgpackArrayHeader(buf *bytes.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.WriteByte(0x90 | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xdc)
+		writeBigEndian(buf, uint64(n), 2)
+	default:
+		buf.WriteByte(0xdd)
+		writeBigEndian(buf, uint64(n), 4)
+	}
+}
+
+func writeMsgpackString(buf *bytes.Buffer, s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xda)
+		writeBigEndian(buf, uint64(n), 2)
+	default:
+		buf.WriteByte(0xdb)
+		writeBigEndian(buf, uint64(n), 4)
+	}
+	buf.WriteString(s)
+}
+
+func writeMsgpackInt(buf *bytes.Buffer, v int64) {
+	buf.WriteByte(0xd3)
+	writeBigEndian(buf, uint64(v), 8)
+}
+
+func writeMsgpackFloat(buf *bytes.Buffer, v float64) {
+	buf.WriteByte(0xcb)
+	writeBigEndian(buf, math.Float64bits(v), 8)
+}
+
+// Writes the lowest size bytes of v in big-endian
+func writeBigEndian(buf *bytes.Buffer, v uint64, size int) {
+	for shift := uint(size-1) * 8; ; shift -= 8 {
+		buf.WriteByte(byte(v >> shift))
+		if shift == 0 {
+			return
+		}
+	}
+}
+
+func writeMsgpackValue(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case string:
+		writeMsgpackString(buf, v)
+	case int:
+		writeMsgpackInt(buf, int64(v))
+	case int32:
+		writeMsgpackInt(buf, int64(v))
+	case int64:
+		writeMsgpackInt(buf, v)
+	case uint32:
+		writeMsgpackInt(buf, int64(v))
+	case uint64:
+		buf.WriteByte(0xcf)
+		writeBigEndian(buf, v, 8)
+	case float32:
+		writeMsgpackFloat(buf, float64(v))
+	case float64:
+		writeMsgpackFloat(buf, v)
+	default:
+		return fmt.Errorf("Unsupported type of value for msgpack: %T", value)
+	}
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalMsgpackParams(t *testing.T) {
+	params := []ParamToAgent{
+		{Metric: "nqm-fping", Endpoint: "agent-1", Value: "12.5", CounterType: "GAUGE", Tags: "a=1", Timestamp: 1500000000, Step: 60},
+		{Metric: "nqm-tcpping", Endpoint: "agent-2", Value: 0.25, Timestamp: 1500000060, Step: 60},
+		{Metric: "nqm-tcpconn", Value: 3, Step: 60},
+		{Metric: "nqm-tcpconn", Value: nil, Tags: string(bytes.Repeat([]byte("t"), 300)), Step: 60},
+	}
+
+	body, err := marshalMsgpackParams(params)
+	if err != nil {
+		t.Fatalf("Marshal has error: %v", err)
+	}
+
+	decoded, err := decodeMsgpack(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Decode has error: %v", err)
+	}
+
+	var expected []interface{}
+	for _, p := range params {
+		value := p.Value
+		if intValue, ok := value.(int); ok {
+			value = int64(intValue)
+		}
+
+		expected = append(expected, map[string]interface{}{
+			"metric": p.Metric, "endpoint": p.Endpoint, "value": value,
+			"counterType": p.CounterType, "tags": p.Tags,
+			"timestamp": p.Timestamp, "step": p.Step,
+		})
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("Expected: %v, got: %v", expected, decoded)
+	}
+
+	if _, err := marshalMsgpackParams([]ParamToAgent{{Value: struct{}{}}}); err == nil {
+		t.Errorf("Expected error for unsupported type of value")
+	}
+}
+
+func TestPushWithMsgpack(t *testing.T) {
+	var contentType string
+	var decoded interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		decoded, _ = decodeMsgpack(r.Body)
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushFormat: PushFormatMsgpack})
+
+	if err := Push(newSampleParams(2), "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+
+	if contentType != "application/x-msgpack" {
+		t.Errorf("Expected Content-Type: application/x-msgpack, got: %q", contentType)
+	}
+	if array, ok := decoded.([]interface{}); !ok || len(array) != 2 {
+		t.Errorf("Expected 2 decoded params, got: %v", decoded)
+	}
+}
+
+func TestGetPushEncoderWithUnsupportedFormat(t *testing.T) {
+	if _, err := getPushEncoder("protobuf"); err == nil {
+		t.Errorf("Expected error for unsupported format")
+	}
+}
+
+func TestPushWithRegisteredEncoder(t *testing.T) {
+	RegisterPushEncoder("csv", func(params []ParamToAgent) ([]byte, string, error) {
+		var buf bytes.Buffer
+		for _, p := range params {
+			fmt.Fprintf(&buf, "%s,%v\n", p.Metric, p.Value)
+		}
+		return buf.Bytes(), "text/csv", nil
+	})
+	defer func() {
+		pushEncodersLock.Lock()
+		delete(pushEncoders, "csv")
+		pushEncodersLock.Unlock()
+	}()
+
+	var contentType string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushFormat: "csv"})
+	if err := InitPushClient(); err != nil {
+		t.Fatalf("InitPushClient has error: %v", err)
+	}
+
+	if err := Push(newSampleParams(2), "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+
+	if contentType != "text/csv" {
+		t.Errorf("Expected Content-Type: text/csv, got: %q", contentType)
+	}
+	if string(body) != "nqm-fping,1\nnqm-fping,1\n" {
+		t.Errorf("Expected body of registered encoder, got: %q", body)
+	}
+}
+
+func TestInitPushClientWithUnknownFormat(t *testing.T) {
+	setPushConfig(&AgentConfig{PushURL: "http://127.0.0.1:1", PushFormat: "protobuf"})
+
+	if err := InitPushClient(); err == nil {
+		t.Errorf("Expected error of InitPushClient for unknown format")
+	}
+}
+
+func BenchmarkMarshalJSONParams(b *testing.B) {
+	params := newSampleParams(1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		marshalJSONParams(params)
+	}
+}
+
+func BenchmarkMarshalMsgpackParams(b *testing.B) {
+	params := newSampleParams(1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		marshalMsgpackParams(params)
+	}
+}
+
+// Decodes the subset of msgpack produced by marshalMsgpackParams
+func decodeMsgpack(r io.Reader) (interface{}, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return nil, err
+	}
+
+	readString := func(n int) (interface{}, error) {
+		s := make([]byte, n)
+		_, err := io.ReadFull(r, s)
+		return string(s), err
+	}
+	readArray := func(n int) (interface{}, error) {
+		array := make([]interface{}, n)
+		for i := range array {
+			v, err := decodeMsgpack(r)
+			if err != nil {
+				return nil, err
+			}
+			array[i] = v
+		}
+		return array, nil
+	}
+
+	switch code := b[0]; {
+	case code&0xf0 == 0x80:
+		m := make(map[string]interface{})
+		for i := 0; i < int(code&0x0f); i++ {
+			k, err := decodeMsgpack(r)
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeMsgpack(r)
+			if err != nil {
+				return nil, err
+			}
+			m[k.(string)] = v
+		}
+		return m, nil
+	case code&0xf0 == 0x90:
+		return readArray(int(code & 0x0f))
+	case code == 0xdc:
+		var n uint16
+		binary.Read(r, binary.BigEndian, &n)
+		return readArray(int(n))
+	case code&0xe0 == 0xa0:
+		return readString(int(code & 0x1f))
+	case code == 0xd9:
+		var n uint8
+		binary.Read(r, binary.BigEndian, &n)
+		return readString(int(n))
+	case code == 0xda:
+		var n uint16
+		binary.Read(r, binary.BigEndian, &n)
+		return readString(int(n))
+	case code == 0xc0:
+		return nil, nil
+	case code == 0xc2, code == 0xc3:
+		return code == 0xc3, nil
+	case code == 0xd3:
+		var v int64
+		err := binary.Read(r, binary.BigEndian, &v)
+		return v, err
+	case code == 0xcf:
+		var v uint64
+		err := binary.Read(r, binary.BigEndian, &v)
+		return v, err
+	case code == 0xcb:
+		var v uint64
+		err := binary.Read(r, binary.BigEndian, &v)
+		return math.Float64frombits(v), err
+	}
+
+	return nil, fmt.Errorf("Unsupported code of msgpack: 0x%x", b[0])
+}
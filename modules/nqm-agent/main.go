@@ -8,6 +8,7 @@ import (
 	"github.com/Cepave/open-falcon-backend/common/logruslog"
 	"github.com/Cepave/open-falcon-backend/common/vipercfg"
 	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
 )
 
 func main() {
@@ -31,15 +32,23 @@ func main() {
 		fmt.Println("Config file changed:", e.Name)
 		InitConfig()
 		logruslog.Init()
+		if err := ReloadPushClient(); err != nil {
+			log.Errorln("Reloading push client...failed:", err)
+		}
 		hbsTickerUpdated <- true
 		GenMeta()
 		InitRPC()
 	})
 
+	if err := InitPushClient(); err != nil {
+		log.Fatalln("Initializing push client...failed:", err)
+	}
+
 	GenMeta()
 	InitRPC()
 
 	go Query()
+	go ReplaySpool()
 	Measure()
 
 	select {}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -32,6 +33,93 @@ func (p ParamToAgent) String() string {
 	)
 }
 
+// Checks the param is qualified to be pushed
+//
+// The metric must not be empty, the value must not be NaN or Inf, and the step must be positive.
+func (p ParamToAgent) Validate() error {
+	if p.Metric == "" {
+		return fmt.Errorf("Metric is empty")
+	}
+	if p.Step <= 0 {
+		return fmt.Errorf("Step is non-positive: %d. Metric: %s", p.Step, p.Metric)
+	}
+
+	var floatValue float64
+	switch v := p.Value.(type) {
+	case float64:
+		floatValue = v
+	case float32:
+		floatValue = float64(v)
+	case string:
+		// Non-numeric string is left to the server
+		floatValue, _ = strconv.ParseFloat(v, 64)
+	}
+	if math.IsNaN(floatValue) || math.IsInf(floatValue, 0) {
+		return fmt.Errorf("Value is NaN or Inf: %v. Metric: %s", p.Value, p.Metric)
+	}
+
+	return nil
+}
+
+// Builder of ParamToAgent
+//
+//	param, err := NewParamToAgent(Meta().Hostname, "nqm-fping").
+//		WithValue("0").WithCounterType("GAUGE").WithStep(60).
+//		Build()
+type ParamToAgentBuilder struct {
+	param ParamToAgent
+}
+
+// Starts building of param with the endpoint and the metric
+func NewParamToAgent(endpoint, metric string) *ParamToAgentBuilder {
+	return &ParamToAgentBuilder{ParamToAgent{Endpoint: endpoint, Metric: metric}}
+}
+
+func (b *ParamToAgentBuilder) WithValue(value interface{}) *ParamToAgentBuilder {
+	b.param.Value = value
+	return b
+}
+func (b *ParamToAgentBuilder) WithStep(step int64) *ParamToAgentBuilder {
+	b.param.Step = step
+	return b
+}
+func (b *ParamToAgentBuilder) WithCounterType(counterType string) *ParamToAgentBuilder {
+	b.param.CounterType = counterType
+	return b
+}
+func (b *ParamToAgentBuilder) WithTags(tags string) *ParamToAgentBuilder {
+	b.param.Tags = tags
+	return b
+}
+func (b *ParamToAgentBuilder) WithTimestamp(timestamp int64) *ParamToAgentBuilder {
+	b.param.Timestamp = timestamp
+	return b
+}
+
+// Builds the param
+//
+// The endpoint and the counter type must not be empty, and the param must pass Validate().
+// The current time is used if the timestamp is not set.
+func (b *ParamToAgentBuilder) Build() (ParamToAgent, error) {
+	param := b.param
+
+	if param.Endpoint == "" {
+		return ParamToAgent{}, fmt.Errorf("Endpoint is empty. Metric: %s", param.Metric)
+	}
+	if param.CounterType == "" {
+		return ParamToAgent{}, fmt.Errorf("Counter type is empty. Metric: %s", param.Metric)
+	}
+	if err := param.Validate(); err != nil {
+		return ParamToAgent{}, err
+	}
+
+	if param.Timestamp == 0 {
+		param.Timestamp = time.Now().Unix()
+	}
+
+	return param, nil
+}
+
 type nqmNodeData struct {
 	Id          string
 	IspId       string
@@ -162,3 +162,41 @@ func TestConvIntSlcToStr(t *testing.T) {
 		}
 	}
 }
+
+func TestParamToAgentBuilder(t *testing.T) {
+	param, err := NewParamToAgent("agent-1", "nqm-fping").
+		WithValue("12.5").WithStep(60).WithCounterType("GAUGE").
+		WithTags("a=1").WithTimestamp(1500000000).
+		Build()
+	if err != nil {
+		t.Fatalf("Build has error: %v", err)
+	}
+
+	expected := ParamToAgent{
+		Metric: "nqm-fping", Endpoint: "agent-1", Value: "12.5", CounterType: "GAUGE",
+		Tags: "a=1", Timestamp: 1500000000, Step: 60,
+	}
+	if param != expected {
+		t.Errorf("Expected: %v, got: %v", expected, param)
+	}
+
+	param, _ = NewParamToAgent("agent-1", "nqm-fping").WithStep(60).WithCounterType("GAUGE").Build()
+	if param.Timestamp == 0 {
+		t.Errorf("Expected current time as timestamp")
+	}
+}
+
+func TestParamToAgentBuilderWithMissingFields(t *testing.T) {
+	tests := []*ParamToAgentBuilder{
+		NewParamToAgent("agent-1", "").WithStep(60).WithCounterType("GAUGE"),
+		NewParamToAgent("", "nqm-fping").WithStep(60).WithCounterType("GAUGE"),
+		NewParamToAgent("agent-1", "nqm-fping").WithStep(60),
+		NewParamToAgent("agent-1", "nqm-fping").WithCounterType("GAUGE"),
+	}
+
+	for i, builder := range tests {
+		if _, err := builder.Build(); err == nil {
+			t.Errorf("Case %d: expected error for missing field", i+1)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"strconv"
 	"time"
 
@@ -132,13 +133,14 @@ func measureByUtil(u Utility, dur chan time.Duration) {
 		log.Println(err)
 		return
 	}
-	log.Println("[", u.UtilName(), "] Measuring...")
+	ctx := WithPushRequestID(context.Background(), newPushRequestID())
+	log.Println("[", u.UtilName(), "] [ Request ID:", PushRequestID(ctx), "] Measuring...")
 
 	rawData := Probe(probingCmd, u.UtilName())
 	parsedData := Parse(rawData)
 	statsData := Calc(parsedData, u)
 	jsonParams := Marshal(statsData, u, targets, agent, int64(interval))
-	Push(jsonParams, u.UtilName())
+	PushOrLogWithContext(ctx, jsonParams, u.UtilName())
 }
 
 func measure(u Utility) {
@@ -2,28 +2,845 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"mime"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
-func Push(params []ParamToAgent, util string) {
-	paramsBody, err := json.Marshal(params)
+// The maximum size of response body kept in PushStatusError
+const maxSizeOfErrorBody = 512
+
+const (
+	defaultPushTimeout          = 5 * time.Second
+	defaultPushRetryMaxInterval = 30 * time.Second
+	defaultPushMarshalWarnTime  = time.Second
+	pushMaxIdleConns            = 10
+	pushIdleConnTimeout         = 90 * time.Second
+)
+
+var (
+	pushClient     *http.Client
+	pushClientLock sync.Mutex
+)
+
+// Sets the shared client of HTTP used by push
+//
+// Passing nil would make the client to be re-built from the configuration on next push.
+func SetPushClient(client *http.Client) {
+	pushClientLock.Lock()
+	defer pushClientLock.Unlock()
+
+	pushClient = client
+}
+
+// Builds the shared client of HTTP by the configuration
+//
+// This function should be called at startup to fail fast if the configuration of TLS or PushFormat is invalid.
+func InitPushClient() error {
+	client, err := newPushClient()
 	if err != nil {
-		log.Fatalln("[", util, "] , Error on formatting body:,", err)
+		return err
 	}
 
-	postReq, err := http.NewRequest("POST", Config().Agent.PushURL, bytes.NewBuffer(paramsBody))
-	postReq.Header.Set("Content-Type", "application/json; charset=UTF-8")
-	postReq.Header.Set("Connection", "close")
+	SetPushClient(client)
+	return nil
+}
 
-	httpClient := &http.Client{}
-	postResp, err := httpClient.Do(postReq)
+// Rebuilds the shared client of HTTP by current configuration, which should be called after the configuration is changed
+//
+// The timeout and TLS take effect on following pushes, the URLs of targets are always read from current configuration.
+// If the new configuration is invalid, the current client is kept and the error is returned.
+func ReloadPushClient() error {
+	client, err := newPushClient()
 	if err != nil {
-		log.Println("[", util, "] Error on push:", err)
+		return err
+	}
+
+	pushClientLock.Lock()
+	oldClient := pushClient
+	pushClient = client
+	pushClientLock.Unlock()
+
+	if oldClient != nil {
+		oldClient.CloseIdleConnections()
+	}
+
+	return nil
+}
+
+// Gets the shared client of HTTP, which is built once with the configuration
+//
+// The connections are kept alive and reused among pushes.
+func getPushClient() (*http.Client, error) {
+	pushClientLock.Lock()
+	defer pushClientLock.Unlock()
+
+	if pushClient == nil {
+		client, err := newPushClient()
+		if err != nil {
+			return nil, err
+		}
+		pushClient = client
+	}
+
+	return pushClient, nil
+}
+
+func newPushClient() (*http.Client, error) {
+	if _, err := getPushEncoder(Config().Agent.PushFormat); err != nil {
+		return nil, err
+	}
+
+	timeout := Config().Agent.PushTimeout * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultPushTimeout
+	}
+
+	tlsConfig, err := buildPushTLSConfig(Config().Agent.PushTLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			TLSClientConfig:     tlsConfig,
+			MaxIdleConns:        pushMaxIdleConns,
+			MaxIdleConnsPerHost: pushMaxIdleConns,
+			IdleConnTimeout:     pushIdleConnTimeout,
+		},
+	}, nil
+}
+
+// The counters of push, which are accessed by sync/atomic
+var (
+	pushSuccessCount  uint64
+	pushFailureCount  uint64
+	pushDroppedParams uint64
+	pushRateLimited   uint64
+	pushOversized     uint64
+	pushEmptySkipped  uint64
+
+	pushMarshalCount uint64
+	pushMarshalNanos int64
+	pushHTTPCount    uint64
+	pushHTTPNanos    int64
+)
+
+// The snapshot of counters of push
+type PushStatistics struct {
+	// The number of succeeded pushes, a push succeeded after retries is counted once
+	SuccessCount uint64
+	// The number of pushes failed after all of the retries
+	FailureCount uint64
+	// The number of params thrown away, which are invalid, dropped by rate limit, failed with non-retryable error(e.g. 4xx or oversized body),
+	// failed without being spooled by PushOrLog(), or evicted from spool.
+	// The params kept in spool for replaying are not counted.
+	DroppedParams uint64
+	// The number of pushes dropped by rate limit
+	RateLimitedCount uint64
+	// The number of pushes dropped because the body exceeds PushMaxBodyBytes
+	OversizedCount uint64
+	// The number of pushes skipped because of empty params, no HTTP request is made for them
+	EmptySkippedCount uint64
+
+	// The number of built bodies and the total time of building them(marshalling and compression)
+	MarshalCount uint64
+	MarshalTime  time.Duration
+	// The number of HTTP requests(including retries) and the total time of them until the responses are received
+	HTTPCount uint64
+	HTTPTime  time.Duration
+}
+
+// Gets the snapshot of counters of push
+func PushStats() PushStatistics {
+	return PushStatistics{
+		SuccessCount:  atomic.LoadUint64(&pushSuccessCount),
+		FailureCount:  atomic.LoadUint64(&pushFailureCount),
+		DroppedParams: atomic.LoadUint64(&pushDroppedParams),
+
+		RateLimitedCount: atomic.LoadUint64(&pushRateLimited),
+		OversizedCount:   atomic.LoadUint64(&pushOversized),
+
+		EmptySkippedCount: atomic.LoadUint64(&pushEmptySkipped),
+
+		MarshalCount: atomic.LoadUint64(&pushMarshalCount),
+		MarshalTime:  time.Duration(atomic.LoadInt64(&pushMarshalNanos)),
+		HTTPCount:    atomic.LoadUint64(&pushHTTPCount),
+		HTTPTime:     time.Duration(atomic.LoadInt64(&pushHTTPNanos)),
+	}
+}
+
+// The error of push dropped by rate limit
+var ErrPushRateLimited = errors.New("Push has been dropped by rate limit")
+
+// The error of push dropped because the body exceeds PushMaxBodyBytes
+//
+// The error is never retried or spooled, since the same body would always exceed the limit.
+var ErrPushBodyTooLarge = errors.New("Body of push is too large")
+
+// The error of push dropped because all of the params are invalid
+var ErrPushInvalidParams = errors.New("All of the params are invalid")
+
+// The error of push with non-2xx status code of HTTP
+type PushStatusError struct {
+	StatusCode int
+	// The first 512 bytes of response body
+	Body string
+}
+
+func (e *PushStatusError) Error() string {
+	return fmt.Sprintf("Push has non-2xx HTTP status: %d. Body: %q", e.StatusCode, e.Body)
+}
+
+// Pushes the params and logs the result
+//
+// The params are saved into spool directory if the push has failed with retryable error(see IsPushRetryable)
+// and the directory is set. The params failed with other errors(e.g. 4xx or ErrPushBodyTooLarge) are dropped,
+// since they would never succeed by replaying. The params dropped by rate limit(ErrPushRateLimited) are not spooled either.
+//
+// The push of empty params is logged as skipped rather than succeeded.
+func PushOrLog(params []ParamToAgent, util string) {
+	PushOrLogWithContext(context.Background(), params, util)
+}
+
+// Pushes the params with the context and logs the result with the ID of request(see WithPushRequestID())
+func PushOrLogWithContext(ctx context.Context, params []ParamToAgent, util string) {
+	ctx, requestID := ensurePushRequestID(ctx)
+
+	if err := PushWithContext(ctx, params, util); err != nil {
+		log.Println("[", util, "] [ Request ID:", requestID, "] Error on push:", err)
+
+		if errors.Is(err, ErrPushBodyTooLarge) {
+			log.Warnln("[", util, "] [ Request ID:", requestID, "] Dropping", len(params), "param(s) of oversized body")
+			return
+		}
+		if errors.Is(err, ErrPushRateLimited) {
+			log.Warnln("[", util, "] [ Request ID:", requestID, "] Dropping", len(params), "param(s) by rate limit")
+			atomic.AddUint64(&pushDroppedParams, uint64(len(selectValidParams(params))))
+			return
+		}
+		if !isRetryablePushError(err) {
+			log.Warnln("[", util, "] [ Request ID:", requestID, "] Dropping", len(params), "param(s) of non-retryable push")
+			return
+		}
+
+		// The invalid params have been counted as dropped ones
+		keptParams := selectValidParams(params)
+		if Config().Agent.SpoolDir == "" {
+			atomic.AddUint64(&pushDroppedParams, uint64(len(keptParams)))
+			return
+		}
+		if err := spoolParams(keptParams, util); err != nil {
+			log.Errorln("[", util, "] Error on spooling params:", err)
+			atomic.AddUint64(&pushDroppedParams, uint64(len(keptParams)))
+		}
 		return
 	}
-	defer postResp.Body.Close()
-	log.Println("[", util, "] Pushing the HTTP Body...succeeded")
+
+	if len(params) == 0 {
+		log.Println("[", util, "] [ Request ID:", requestID, "] Pushing the HTTP Body...skipped(no params)")
+		return
+	}
+
+	log.Println("[", util, "] [ Request ID:", requestID, "] Pushing the HTTP Body...succeeded")
+}
+
+// Pushes the params with retries
+//
+// The error is the transport error or *PushStatusError of the last attempt.
+func Push(params []ParamToAgent, util string) error {
+	return PushWithContext(context.Background(), params, util)
+}
+
+// Pushes the params with retries, which is cancelled while the context is done
+//
+// The error wraps the error of context if the push is cancelled.
+//
+// If the params are empty, the push is skipped(counted by EmptySkippedCount of PushStats()) without error.
+//
+// The ID of request is generated if the context doesn't have one.
+func PushWithContext(ctx context.Context, params []ParamToAgent, util string) error {
+	ctx, _ = ensurePushRequestID(ctx)
+	return pushWithContext(ctx, params, util, false)
+}
+
+// If splitOversized is true, the params are split into halves while the body exceeds PushMaxBodyBytes
+func pushWithContext(ctx context.Context, params []ParamToAgent, util string, splitOversized bool) error {
+	if len(params) == 0 {
+		atomic.AddUint64(&pushEmptySkipped, 1)
+		log.Debugln("[", util, "] Skipping push of empty params")
+		return nil
+	}
+
+	validParams := filterValidParams(params, util)
+	if len(validParams) == 0 {
+		atomic.AddUint64(&pushFailureCount, 1)
+		return fmt.Errorf("%w: %d param(s)", ErrPushInvalidParams, len(params))
+	}
+	params = validParams
+
+	if Config().Agent.PushDedup {
+		params = dedupParams(params, util)
+	}
+
+	return deliverPush(ctx, len(params), func(ctx context.Context) error {
+		return pushWithRetries(ctx, params, util, splitOversized)
+	})
+}
+
+// Pushes the pre-built body with retries, e.g. the marshalled params kept by caller
+//
+// The body is posted as it is(PushGzip is not applied), the content type of JSON is used if "contentType" is empty.
+// The rate limit, circuit breaker, and PushMaxBodyBytes are applied as same as Push().
+func PushRaw(body io.Reader, contentType string, util string) error {
+	return PushRawWithContext(context.Background(), body, contentType, util)
+}
+
+// Pushes the pre-built body with retries, which is cancelled while the context is done
+func PushRawWithContext(ctx context.Context, body io.Reader, contentType string, util string) error {
+	ctx, _ = ensurePushRequestID(ctx)
+
+	payload, err := buildRawPushPayload(body, contentType)
+	if err != nil {
+		atomic.AddUint64(&pushFailureCount, 1)
+		return err
+	}
+
+	return deliverPush(ctx, 0, func(ctx context.Context) error {
+		return pushPayloadWithRetries(ctx, payload, util)
+	})
+}
+
+func buildRawPushPayload(body io.Reader, contentType string) (*pushPayload, error) {
+	if contentType == "" {
+		contentType = jsonContentType
+	}
+	if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		return nil, fmt.Errorf("Invalid content type of push: %q. Error: %v", contentType, err)
+	}
+
+	rawBody, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("Error on reading body: %v", err)
+	}
+
+	if maxBodyBytes := Config().Agent.PushMaxBodyBytes; maxBodyBytes > 0 && len(rawBody) > maxBodyBytes {
+		atomic.AddUint64(&pushOversized, 1)
+		return nil, fmt.Errorf("%w: %d bytes, the limit is %d bytes", ErrPushBodyTooLarge, len(rawBody), maxBodyBytes)
+	}
+
+	return &pushPayload{body: rawBody, contentType: contentType, idempotencyKey: pushIdempotencyKey(rawBody)}, nil
+}
+
+// Sends the push under rate limit and circuit breaker, the counters of push are updated by the result
+//
+// The "numberOfParams" is counted as dropped params if the push has failed with non-retryable error,
+// the params failed with retryable error(or ErrPushRateLimited) are left to caller(e.g. spooled by PushOrLog()).
+func deliverPush(ctx context.Context, numberOfParams int, send func(ctx context.Context) error) error {
+	permitted, err := waitPushLimit(ctx)
+	if err != nil {
+		return fmt.Errorf("Push has been cancelled while waiting for rate limit: %w", err)
+	}
+	if !permitted {
+		atomic.AddUint64(&pushRateLimited, 1)
+		return ErrPushRateLimited
+	}
+
+	if !pushBreaker.allow() {
+		atomic.AddUint64(&pushFailureCount, 1)
+		return ErrPushCircuitOpen
+	}
+
+	attemptsCtx, attempts := withPushAttempts(ctx)
+	err = send(attemptsCtx)
+	if (err != nil && ctx.Err() != nil) || atomic.LoadInt32(attempts) == 0 {
+		// Neither the cancelled push nor the one rejected locally(e.g. ErrPushBodyTooLarge) tells the health of server
+		pushBreaker.release()
+	} else {
+		pushBreaker.record(err == nil || !isRetryablePushError(err))
+	}
+	if err != nil {
+		atomic.AddUint64(&pushFailureCount, 1)
+		if !isRetryablePushError(err) {
+			atomic.AddUint64(&pushDroppedParams, uint64(numberOfParams))
+		}
+		return err
+	}
+
+	atomic.AddUint64(&pushSuccessCount, 1)
+	return nil
+}
+
+// Filters out invalid params, which are counted as dropped params
+func filterValidParams(params []ParamToAgent, util string) []ParamToAgent {
+	validParams := make([]ParamToAgent, 0, len(params))
+	for _, param := range params {
+		if err := param.Validate(); err != nil {
+			log.Debugln("[", util, "] Invalid param:", err)
+			continue
+		}
+		validParams = append(validParams, param)
+	}
+
+	if dropped := len(params) - len(validParams); dropped > 0 {
+		atomic.AddUint64(&pushDroppedParams, uint64(dropped))
+		log.Warnln("[", util, "] Dropped", dropped, "invalid param(s)")
+	}
+
+	return validParams
+}
+
+// Selects the valid params without logging and counting
+func selectValidParams(params []ParamToAgent) []ParamToAgent {
+	validParams := make([]ParamToAgent, 0, len(params))
+	for _, param := range params {
+		if param.Validate() == nil {
+			validParams = append(validParams, param)
+		}
+	}
+
+	return validParams
+}
+
+// The identity of param for deduplication
+type paramKey struct {
+	endpoint  string
+	metric    string
+	tags      string
+	timestamp int64
+}
+
+// Collapses params with identical endpoint, metric, tags and timestamp
+//
+// The position of the first one is kept, with the value of the last one.
+func dedupParams(params []ParamToAgent, util string) []ParamToAgent {
+	dedupedParams := make([]ParamToAgent, 0, len(params))
+	indexes := make(map[paramKey]int, len(params))
+	for _, param := range params {
+		key := paramKey{param.Endpoint, param.Metric, param.Tags, param.Timestamp}
+		if i, ok := indexes[key]; ok {
+			dedupedParams[i] = param
+			continue
+		}
+
+		indexes[key] = len(dedupedParams)
+		dedupedParams = append(dedupedParams, param)
+	}
+
+	if removed := len(params) - len(dedupedParams); removed > 0 {
+		log.Infoln("[", util, "] Removed", removed, "duplicated param(s)")
+	}
+
+	return dedupedParams
+}
+
+func pushWithRetries(ctx context.Context, params []ParamToAgent, util string, splitOversized bool) error {
+	payload, err := buildTimedPushPayload(params, util)
+	if err != nil {
+		return err
+	}
+
+	if maxBodyBytes := Config().Agent.PushMaxBodyBytes; maxBodyBytes > 0 && len(payload.body) > maxBodyBytes {
+		if !splitOversized || len(params) <= 1 {
+			atomic.AddUint64(&pushOversized, 1)
+			return fmt.Errorf(
+				"%w: %d bytes of %d param(s), the limit is %d bytes",
+				ErrPushBodyTooLarge, len(payload.body), len(params), maxBodyBytes,
+			)
+		}
+
+		log.Debugln("[", util, "] Splitting", len(params), "param(s) of oversized body:", len(payload.body), "bytes")
+
+		middle := len(params) / 2
+		return errors.Join(
+			pushWithRetries(ctx, params[:middle], util, true),
+			pushWithRetries(ctx, params[middle:], util, true),
+		)
+	}
+
+	return pushPayloadWithRetries(ctx, payload, util)
+}
+
+// The retrying is stopped if the next attempt would start after PushDeadline since the first attempt,
+// and the in-flight attempt is cancelled at the deadline
+func pushPayloadWithRetries(ctx context.Context, payload *pushPayload, util string) error {
+	retries := Config().Agent.PushRetries
+
+	/**
+	 * The in-flight attempt is cut off by the deadline
+	 */
+	var deadline time.Time
+	attemptCtx := ctx
+	if pushDeadline := Config().Agent.PushDeadline * time.Millisecond; pushDeadline > 0 {
+		deadline = time.Now().Add(pushDeadline)
+
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	// :~)
+
+	for attempt := 1; ; attempt++ {
+		err := pushToTargets(attemptCtx, payload, util)
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() == nil && attemptCtx.Err() != nil {
+			return fmt.Errorf("Push has exceeded the deadline after %d attempt(s): %w", attempt, err)
+		}
+
+		if attempt > retries || ctx.Err() != nil || !isRetryablePushError(err) {
+			return fmt.Errorf("Push has failed after %d attempt(s): %w", attempt, err)
+		}
+
+		delay := pushRetryDelay(attempt)
+		if !deadline.IsZero() && time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("Push has exceeded the deadline after %d attempt(s): %w", attempt, err)
+		}
+
+		log.Debugln("[", util, "] Retrying push, attempt", attempt, "has failed:", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("Push has been cancelled after %d attempt(s): %w", attempt, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// Gives a random number in [0, n), which could be replaced for testing
+var pushJitter = rand.Int63n
+
+// Gets the delay before the retry after the failed attempt
+//
+// The delay is a random duration(full jitter) in [0, PushRetryInterval * 2^(attempt - 1)],
+// which is capped at PushRetryMaxInterval.
+func pushRetryDelay(attempt int) time.Duration {
+	baseInterval := Config().Agent.PushRetryInterval * time.Millisecond
+	maxInterval := Config().Agent.PushRetryMaxInterval * time.Millisecond
+	if maxInterval <= 0 {
+		maxInterval = defaultPushRetryMaxInterval
+	}
+	if baseInterval <= 0 {
+		return 0
+	}
+
+	interval := maxInterval
+	if shift := uint(attempt - 1); shift < 63 && baseInterval <= maxInterval>>shift {
+		interval = baseInterval << shift
+	}
+
+	return time.Duration(pushJitter(int64(interval) + 1))
+}
+
+// The summary of batched push
+type PushResult struct {
+	// The number of chunks
+	Chunks int
+	// The number of delivered chunks
+	Succeeded int
+	// The number of failed chunks
+	Failed int
+	// The errors(as *PushChunkError) of failed chunks in order
+	Errors []error
+}
+
+// Gets the combination of errors of failed chunks, nil if all of the chunks are delivered
+func (r PushResult) Err() error {
+	return errors.Join(r.Errors...)
+}
+
+// The error of failed chunk, the params of chunk are params[Start:End] of batched push
+type PushChunkError struct {
+	// The 1-based index of chunk
+	Index int
+	Start int
+	End   int
+	Err   error
+}
+
+func (e *PushChunkError) Error() string {
+	return fmt.Sprintf("Chunk #%d([%d:%d]): %v", e.Index, e.Start, e.End, e.Err)
+}
+func (e *PushChunkError) Unwrap() error {
+	return e.Err
+}
+
+// Pushes the params by chunks, every chunk has at most chunkSize params
+//
+// The failure of a chunk doesn't prevent the remaining chunks from being pushed,
+// the outcome of every chunk(after retries) is summarized in the result.
+//
+// If the body of a chunk exceeds PushMaxBodyBytes, the chunk is split into halves until the body fits.
+// All of the chunks share the same ID of request.
+func PushBatched(params []ParamToAgent, util string, chunkSize int) PushResult {
+	if chunkSize <= 0 {
+		chunkSize = len(params)
+	}
+
+	ctx, _ := ensurePushRequestID(context.Background())
+
+	var result PushResult
+	for start := 0; start < len(params); start += chunkSize {
+		end := start + chunkSize
+		if end > len(params) {
+			end = len(params)
+		}
+
+		result.Chunks++
+		if err := pushWithContext(ctx, params[start:end], util, true); err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, &PushChunkError{result.Chunks, start, end, err})
+			continue
+		}
+
+		result.Succeeded++
+	}
+
+	return result
+}
+
+// The counter used to select the first target in round-robin
+var pushTargetCounter uint32
+
+// Gets the URLs of targets, the PushURL is used if PushURLs is empty
+//
+// The PushPath is appended to every URL if it is not empty.
+func pushTargets() []string {
+	urls := Config().Agent.PushURLs
+	if len(urls) == 0 {
+		urls = []string{Config().Agent.PushURL}
+	}
+
+	path := Config().Agent.PushPath
+	if path == "" {
+		return urls
+	}
+
+	targets := make([]string, len(urls))
+	for i, url := range urls {
+		targets[i] = strings.TrimRight(url, "/") + "/" + strings.TrimLeft(path, "/")
+	}
+
+	return targets
+}
+
+// Pushes the body to targets in order until one of them has succeeded
+//
+// If PushRoundRobin is true, the first target is rotated for every call.
+// The error is the one of the last tried target.
+func pushToTargets(ctx context.Context, payload *pushPayload, util string) error {
+	targets := pushTargets()
+
+	offset := 0
+	if Config().Agent.PushRoundRobin {
+		offset = int((atomic.AddUint32(&pushTargetCounter, 1) - 1) % uint32(len(targets)))
+	}
+
+	var err error
+	for i := range targets {
+		url := targets[(offset+i)%len(targets)]
+
+		if err = pushBody(ctx, url, payload); err == nil {
+			if len(targets) > 1 {
+				log.Println("[", util, "] Pushing to", url, "...succeeded")
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+
+		if len(targets) > 1 {
+			log.Debugln("[", util, "] Pushing to", url, "has failed:", err)
+		}
+	}
+
+	return err
+}
+
+// Decides whether or not the failed push is worth retrying
+//
+// The "statusCode" is 0 if the push has failed without response(e.g. error of transport).
+// This variable could be replaced to customize the retrying, e.g. never retry 413.
+var IsPushRetryable func(statusCode int, err error) bool = DefaultIsPushRetryable
+
+// Retries on error of transport, 429, and 5xx
+func DefaultIsPushRetryable(statusCode int, err error) bool {
+	switch {
+	case statusCode == 0:
+		return true
+	case statusCode == http.StatusTooManyRequests:
+		return true
+	}
+
+	return statusCode >= http.StatusInternalServerError
+}
+
+func isRetryablePushError(err error) bool {
+	if errors.Is(err, ErrPushBodyTooLarge) || errors.Is(err, ErrPushInvalidParams) {
+		return false
+	}
+
+	var statusErr *PushStatusError
+	if !errors.As(err, &statusErr) {
+		return IsPushRetryable(0, err)
+	}
+
+	return IsPushRetryable(statusErr.StatusCode, err)
+}
+
+// The encoded body of push with its headers of HTTP
+type pushPayload struct {
+	body            []byte
+	contentType     string
+	contentEncoding string
+	// The hash of serialized body, which is sent as "Idempotency-Key" for every attempt
+	idempotencyKey string
+}
+
+// Gets the key of idempotency by SHA-256 of the serialized body(before compression)
+func pushIdempotencyKey(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Builds the payload and records the time of building, the warning is logged if it takes longer than PushMarshalWarnTime
+func buildTimedPushPayload(params []ParamToAgent, util string) (*pushPayload, error) {
+	startTime := time.Now()
+	payload, err := buildPushPayload(params)
+	elapsed := time.Since(startTime)
+
+	atomic.AddUint64(&pushMarshalCount, 1)
+	atomic.AddInt64(&pushMarshalNanos, int64(elapsed))
+
+	warnTime := Config().Agent.PushMarshalWarnTime * time.Millisecond
+	if warnTime <= 0 {
+		warnTime = defaultPushMarshalWarnTime
+	}
+	if elapsed > warnTime {
+		log.Warnln("[", util, "] Building body of", len(params), "param(s) takes", elapsed, "which exceeds", warnTime)
+	}
+
+	return payload, err
+}
+
+// Encodes the params by PushFormat(with PushContentType if it is set) and compresses the body if PushGzip is true
+// and the size of body exceeds PushGzipMinBytes
+func buildPushPayload(params []ParamToAgent) (*pushPayload, error) {
+	encoder, err := getPushEncoder(Config().Agent.PushFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	body, encodedContentType, err := encoder(params)
+	if err != nil {
+		return nil, fmt.Errorf("Error on formatting body: %v", err)
+	}
+
+	payload := &pushPayload{body: body, contentType: encodedContentType, idempotencyKey: pushIdempotencyKey(body)}
+	if contentType := strings.TrimSpace(Config().Agent.PushContentType); contentType != "" {
+		if _, _, err := mime.ParseMediaType(contentType); err != nil {
+			return nil, fmt.Errorf("Invalid content type of push: %q. Error: %v", contentType, err)
+		}
+		payload.contentType = contentType
+	}
+	if Config().Agent.PushGzip && len(body) > Config().Agent.PushGzipMinBytes {
+		if payload.body, err = gzipBody(body); err != nil {
+			return nil, fmt.Errorf("Error on compressing body: %v", err)
+		}
+		payload.contentEncoding = "gzip"
+	}
+
+	return payload, nil
+}
+
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Sets the extra headers of configuration and then the ones used by push
+//
+// The values of headers must not be logged since they may contain secrets.
+func setPushHeaders(header http.Header, payload *pushPayload) {
+	for name, value := range Config().Agent.PushHeaders {
+		header.Set(name, value)
+	}
+
+	header.Set("Content-Type", payload.contentType)
+	if payload.idempotencyKey != "" {
+		header.Set("Idempotency-Key", payload.idempotencyKey)
+	}
+	if payload.contentEncoding != "" {
+		header.Set("Content-Encoding", payload.contentEncoding)
+	}
+	if token := Config().Agent.PushToken; token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+func pushBody(ctx context.Context, url string, payload *pushPayload) error {
+	postReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload.body))
+	if err != nil {
+		return err
+	}
+	setPushHeaders(postReq.Header, payload)
+	if requestID := PushRequestID(ctx); requestID != "" {
+		postReq.Header.Set(pushRequestIDHeader, requestID)
+	}
+
+	client, err := getPushClient()
+	if err != nil {
+		return err
+	}
+
+	startTime := time.Now()
+	postResp, err := client.Do(postReq)
+	atomic.AddUint64(&pushHTTPCount, 1)
+	countPushAttempt(ctx)
+	atomic.AddInt64(&pushHTTPNanos, int64(time.Since(startTime)))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Drains the body so the connection could be reused
+		io.Copy(ioutil.Discard, postResp.Body)
+		postResp.Body.Close()
+	}()
+
+	if postResp.StatusCode < 200 || postResp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(postResp.Body, maxSizeOfErrorBody))
+		return &PushStatusError{postResp.StatusCode, string(body)}
+	}
+
+	return nil
 }
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// The header of HTTP to correlate the push with the log of server
+const pushRequestIDHeader = "X-Request-ID"
+
+type pushRequestIDKey struct{}
+
+// Gives the ID of request to the pushes with the context, which is sent as "X-Request-ID"
+//
+// The pushes(including retries) with the same context share the ID, e.g. for a full cycle of measurement.
+func WithPushRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, pushRequestIDKey{}, requestID)
+}
+
+// Gets the ID of request in the context, empty string if there is none
+func PushRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(pushRequestIDKey{}).(string)
+	return requestID
+}
+
+// Gets the context with ID of request, a new ID is generated if the context doesn't have one
+func ensurePushRequestID(ctx context.Context) (context.Context, string) {
+	if requestID := PushRequestID(ctx); requestID != "" {
+		return ctx, requestID
+	}
+
+	requestID := newPushRequestID()
+	return WithPushRequestID(ctx, requestID), requestID
+}
+
+// Generates a random ID of 32 hexadecimal digits
+func newPushRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(id)
+}
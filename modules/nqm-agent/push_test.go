@@ -0,0 +1,1051 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func setPushConfig(agentConfig *AgentConfig) {
+	SetConfig(JSONConfig{
+		Agent: agentConfig,
+		Hbs:   &HbsConfig{},
+	})
+
+	SetPushClient(nil)
+
+	pushLimiterLock.Lock()
+	pushLimiter = nil
+	pushLimiterLock.Unlock()
+
+	pushBreaker = newCircuitBreaker()
+}
+
+// Builds valid params with the number of n
+func newSampleParams(n int) []ParamToAgent {
+	params := make([]ParamToAgent, n)
+	for i := range params {
+		params[i] = ParamToAgent{Metric: "nqm-fping", Value: "1", Step: 60}
+	}
+
+	return params
+}
+
+func TestReloadPushClient(t *testing.T) {
+	var oldPosts, newPosts int32
+	oldServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&oldPosts, 1)
+	}))
+	defer oldServer.Close()
+	newServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&newPosts, 1)
+	}))
+	defer newServer.Close()
+
+	setPushConfig(&AgentConfig{PushURL: oldServer.URL})
+	if err := Push(newSampleParams(1), "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+
+	SetConfig(JSONConfig{Agent: &AgentConfig{PushURL: newServer.URL, PushTimeout: 1500}, Hbs: &HbsConfig{}})
+	if err := ReloadPushClient(); err != nil {
+		t.Fatalf("Reload has error: %v", err)
+	}
+	if err := Push(newSampleParams(1), "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+
+	if atomic.LoadInt32(&oldPosts) != 1 || atomic.LoadInt32(&newPosts) != 1 {
+		t.Errorf("Expected the second push to target the new URL, got old: %d, new: %d", oldPosts, newPosts)
+	}
+	if client, _ := getPushClient(); client.Timeout != 1500*time.Millisecond {
+		t.Errorf("Expected timeout of reloaded client: 1.5s, got: %v", client.Timeout)
+	}
+
+	// The invalid configuration keeps the current client
+	client, _ := getPushClient()
+	SetConfig(JSONConfig{
+		Agent: &AgentConfig{PushURL: newServer.URL, PushTLS: &PushTLSConfig{CAFile: "/no-such-file.pem"}},
+		Hbs:   &HbsConfig{},
+	})
+	if err := ReloadPushClient(); err == nil {
+		t.Errorf("Expected error for invalid TLS configuration")
+	}
+	if currentClient, _ := getPushClient(); currentClient != client {
+		t.Errorf("Expected the current client to be kept")
+	}
+}
+
+func TestPushWithRetries(t *testing.T) {
+	var numberOfRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&numberOfRequests, 1) <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		retries          int
+		expectedRequests int32
+	}{
+		{0, 1},
+		{1, 2},
+		{3, 3},
+	}
+
+	for i, v := range tests {
+		atomic.StoreInt32(&numberOfRequests, 0)
+		setPushConfig(&AgentConfig{
+			PushURL:           server.URL,
+			PushRetries:       v.retries,
+			PushRetryInterval: 1,
+		})
+
+		Push([]ParamToAgent{{Metric: "nqm-fping", Step: 60}}, "fping")
+
+		if got := atomic.LoadInt32(&numberOfRequests); got != v.expectedRequests {
+			t.Errorf("Case %d: expected %d requests, got %d", i+1, v.expectedRequests, got)
+		}
+	}
+}
+
+func TestPushWithDeadline(t *testing.T) {
+	var numberOfRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numberOfRequests, 1)
+		time.Sleep(30 * time.Millisecond)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	spoolDir := t.TempDir()
+	setPushConfig(&AgentConfig{
+		PushURL:           server.URL,
+		PushRetries:       10,
+		PushRetryInterval: 1,
+		PushDeadline:      100,
+		SpoolDir:          spoolDir,
+	})
+
+	startTime := time.Now()
+	err := Push(newSampleParams(1), "fping")
+	elapsed := time.Since(startTime)
+
+	if err == nil || !strings.Contains(err.Error(), "exceeded the deadline") {
+		t.Errorf("Expected error of deadline, got: %v", err)
+	}
+	if got := atomic.LoadInt32(&numberOfRequests); got < 2 || got > 4 {
+		t.Errorf("Expected 2 to 4 requests within the deadline, got %d", got)
+	}
+	if elapsed > 200*time.Millisecond {
+		t.Errorf("Expected the retrying to be stopped by deadline, elapsed: %v", elapsed)
+	}
+
+	/**
+	 * The params are spooled after the deadline is exceeded
+	 */
+	PushOrLog(newSampleParams(1), "fping")
+	if files, _ := listSpool(); len(files) != 1 {
+		t.Errorf("Expected 1 spooled file, got: %v", files)
+	}
+	// :~)
+}
+
+func TestPushWithDeadlineOnSlowServer(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	setPushConfig(&AgentConfig{
+		PushURLs:     []string{server.URL, server.URL},
+		PushRetries:  3,
+		PushTimeout:  1000,
+		PushDeadline: 100,
+	})
+
+	startTime := time.Now()
+	err := Push(newSampleParams(1), "fping")
+	elapsed := time.Since(startTime)
+
+	if err == nil || !strings.Contains(err.Error(), "exceeded the deadline after 1 attempt(s)") {
+		t.Errorf("Expected error of deadline, got: %v", err)
+	}
+	if elapsed > 300*time.Millisecond {
+		t.Errorf("Expected the in-flight request to be cut off by deadline, elapsed: %v", elapsed)
+	}
+}
+
+func TestPushWithIdempotencyKey(t *testing.T) {
+	var receivedKeys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedKeys = append(receivedKeys, r.Header.Get("Idempotency-Key"))
+		// Every first attempt of a push is failed
+		if len(receivedKeys)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushRetries: 1, PushGzip: true})
+
+	if err := Push([]ParamToAgent{{Metric: "nqm-fping", Value: 1, Step: 60}}, "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+	if err := Push([]ParamToAgent{{Metric: "nqm-fping", Value: 2, Step: 60}}, "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+
+	if len(receivedKeys) != 4 || receivedKeys[0] == "" {
+		t.Fatalf("Expected 4 requests with Idempotency-Key, got: %q", receivedKeys)
+	}
+	if receivedKeys[0] != receivedKeys[1] || receivedKeys[2] != receivedKeys[3] {
+		t.Errorf("Expected the same key for retries, got: %q", receivedKeys)
+	}
+	if receivedKeys[0] == receivedKeys[2] {
+		t.Errorf("Expected different keys for different payloads, got: %q", receivedKeys)
+	}
+}
+
+func TestDefaultIsPushRetryable(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		err        error
+		expected   bool
+	}{
+		{http.StatusTooManyRequests, &PushStatusError{StatusCode: http.StatusTooManyRequests}, true},
+		{http.StatusInternalServerError, &PushStatusError{StatusCode: http.StatusInternalServerError}, true},
+		{http.StatusNotFound, &PushStatusError{StatusCode: http.StatusNotFound}, false},
+		{0, errors.New("connection refused"), true},
+	}
+
+	for i, v := range tests {
+		if got := DefaultIsPushRetryable(v.statusCode, v.err); got != v.expected {
+			t.Errorf("Case %d: expected %v, got %v", i+1, v.expected, got)
+		}
+		if got := isRetryablePushError(v.err); got != v.expected {
+			t.Errorf("Case %d: expected %v from error, got %v", i+1, v.expected, got)
+		}
+	}
+}
+
+func TestPushWithCustomRetryable(t *testing.T) {
+	var numberOfRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numberOfRequests, 1)
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	}))
+	defer server.Close()
+
+	IsPushRetryable = func(statusCode int, err error) bool {
+		return statusCode != http.StatusRequestEntityTooLarge
+	}
+	defer func() { IsPushRetryable = DefaultIsPushRetryable }()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushRetries: 3, PushRetryInterval: 1})
+	Push(newSampleParams(1), "fping")
+
+	if n := atomic.LoadInt32(&numberOfRequests); n != 1 {
+		t.Errorf("Expected no retry for 413, got %d request(s)", n)
+	}
+}
+
+func TestPushRetryDelay(t *testing.T) {
+	setPushConfig(&AgentConfig{PushRetryInterval: 100, PushRetryMaxInterval: 1000})
+
+	tests := []struct {
+		attempt     int
+		expectedMax time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, 1000 * time.Millisecond},
+		{100, 1000 * time.Millisecond},
+	}
+
+	// The jitter source gives the upper bound
+	pushJitter = func(n int64) int64 { return n - 1 }
+	defer func() { pushJitter = rand.Int63n }()
+
+	for i, v := range tests {
+		if got := pushRetryDelay(v.attempt); got != v.expectedMax {
+			t.Errorf("Case %d: expected max delay %v, got %v", i+1, v.expectedMax, got)
+		}
+	}
+
+	// The delays of real jitter source stay within bounds
+	pushJitter = rand.Int63n
+	for i, v := range tests {
+		for j := 0; j < 100; j++ {
+			if got := pushRetryDelay(v.attempt); got < 0 || got > v.expectedMax {
+				t.Fatalf("Case %d: delay %v is out of [0, %v]", i+1, got, v.expectedMax)
+			}
+		}
+	}
+}
+
+func TestPushWithError(t *testing.T) {
+	failedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failedServer.Close()
+
+	closedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedServer.Close()
+
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer okServer.Close()
+
+	tests := []struct {
+		url           string
+		expectedError bool
+	}{
+		{failedServer.URL, true},
+		{closedServer.URL, true},
+		{okServer.URL, false},
+	}
+
+	for i, v := range tests {
+		setPushConfig(&AgentConfig{PushURL: v.url})
+
+		err := Push([]ParamToAgent{{Metric: "nqm-fping", Step: 60}}, "fping")
+		if (err != nil) != v.expectedError {
+			t.Errorf("Case %d: unexpected error: %v", i+1, err)
+		}
+	}
+
+	setPushConfig(&AgentConfig{PushURL: failedServer.URL})
+	err := Push([]ParamToAgent{{Metric: "nqm-fping", Step: 60}}, "fping")
+
+	var statusErr *PushStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status error of 500, got: %v", err)
+	}
+}
+
+func TestPushOrLogWithFailedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("Service is under maintenance" + strings.Repeat("!", 1024)))
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL})
+
+	logOutput := &bytes.Buffer{}
+	log.SetOutput(logOutput)
+	defer log.SetOutput(os.Stderr)
+
+	PushOrLog([]ParamToAgent{{Metric: "nqm-fping", Step: 60}}, "fping")
+
+	logged := logOutput.String()
+	if strings.Contains(logged, "succeeded") {
+		t.Errorf("The failed push is logged as success: %s", logged)
+	}
+	if !strings.Contains(logged, "503") || !strings.Contains(logged, "Service is under maintenance") {
+		t.Errorf("The status and body are not logged: %s", logged)
+	}
+	if strings.Contains(logged, strings.Repeat("!", 600)) {
+		t.Errorf("The body is not truncated: %s", logged)
+	}
+}
+
+func TestPushWithRequestID(t *testing.T) {
+	var receivedIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedIDs = append(receivedIDs, r.Header.Get("X-Request-ID"))
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL})
+
+	logOutput := &bytes.Buffer{}
+	log.SetOutput(logOutput)
+	defer log.SetOutput(os.Stderr)
+
+	/**
+	 * The generated ID is sent and logged
+	 */
+	PushOrLog(newSampleParams(1), "fping")
+
+	if len(receivedIDs) != 1 || len(receivedIDs[0]) != 32 {
+		t.Fatalf("Expected a generated X-Request-ID, got: %q", receivedIDs)
+	}
+	if !strings.Contains(logOutput.String(), receivedIDs[0]) {
+		t.Errorf("The ID of request %q is not logged: %s", receivedIDs[0], logOutput.String())
+	}
+	// :~)
+
+	/**
+	 * The pushes with the same context share the ID
+	 */
+	ctx := WithPushRequestID(context.Background(), "cycle-1")
+	PushOrLogWithContext(ctx, newSampleParams(1), "fping")
+	if err := PushWithContext(ctx, newSampleParams(1), "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+
+	if !reflect.DeepEqual(receivedIDs[1:], []string{"cycle-1", "cycle-1"}) {
+		t.Errorf("Expected X-Request-ID of context, got: %q", receivedIDs[1:])
+	}
+	if !strings.Contains(logOutput.String(), "cycle-1") {
+		t.Errorf("The ID of request in context is not logged: %s", logOutput.String())
+	}
+	// :~)
+}
+
+func TestPushWithTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushTimeout: 50})
+
+	err := Push([]ParamToAgent{{Metric: "nqm-fping", Step: 60}}, "fping")
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Expected timeout error, got: %v", err)
+	}
+}
+
+func TestPushReusesConnection(t *testing.T) {
+	var newConns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&newConns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL})
+
+	for i := 0; i < 5; i++ {
+		if err := Push([]ParamToAgent{{Metric: "nqm-fping", Step: 60}}, "fping"); err != nil {
+			t.Fatalf("Push has error: %v", err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&newConns); n != 1 {
+		t.Errorf("Expected 1 connection, got: %d", n)
+	}
+}
+
+func BenchmarkPush(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL})
+	params := []ParamToAgent{{Metric: "nqm-fping", Step: 60}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Push(params, "fping")
+	}
+}
+
+func TestPushWithGzip(t *testing.T) {
+	params := []ParamToAgent{
+		{Metric: "nqm-fping", Endpoint: "agent-1", Value: float64(12), Tags: "a=1", Timestamp: 1500000000, Step: 60},
+		{Metric: "nqm-tcpping", Endpoint: "agent-2", Value: float64(0.5), Timestamp: 1500000060, Step: 60},
+	}
+
+	var receivedEncoding string
+	var receivedParams []ParamToAgent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedEncoding = r.Header.Get("Content-Encoding")
+
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("Cannot decompress body: %v", err)
+			return
+		}
+		if err := json.NewDecoder(reader).Decode(&receivedParams); err != nil {
+			t.Errorf("Cannot decode body: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushGzip: true})
+
+	if err := Push(params, "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+
+	if receivedEncoding != "gzip" {
+		t.Errorf("Expected Content-Encoding: gzip, got: %q", receivedEncoding)
+	}
+	if !reflect.DeepEqual(receivedParams, params) {
+		t.Errorf("Expected params: %v, got: %v", params, receivedParams)
+	}
+}
+
+func TestPushWithGzipMinBytes(t *testing.T) {
+	tests := []struct {
+		params           []ParamToAgent
+		expectedEncoding string
+	}{
+		{newSampleParams(1), ""},
+		{newSampleParams(100), "gzip"},
+	}
+
+	for i, v := range tests {
+		var receivedEncoding string
+		var receivedParams []ParamToAgent
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedEncoding = r.Header.Get("Content-Encoding")
+
+			var body io.Reader = r.Body
+			if receivedEncoding == "gzip" {
+				reader, err := gzip.NewReader(r.Body)
+				if err != nil {
+					t.Errorf("Case %d: Cannot decompress body: %v", i+1, err)
+					return
+				}
+				body = reader
+			}
+			if err := json.NewDecoder(body).Decode(&receivedParams); err != nil {
+				t.Errorf("Case %d: Cannot decode body: %v", i+1, err)
+			}
+		}))
+
+		setPushConfig(&AgentConfig{PushURL: server.URL, PushGzip: true, PushGzipMinBytes: 1024})
+
+		if err := Push(v.params, "fping"); err != nil {
+			t.Fatalf("Case %d: Push has error: %v", i+1, err)
+		}
+		server.Close()
+
+		if receivedEncoding != v.expectedEncoding {
+			t.Errorf("Case %d: Expected Content-Encoding: %q, got: %q", i+1, v.expectedEncoding, receivedEncoding)
+		}
+		if !reflect.DeepEqual(receivedParams, v.params) {
+			t.Errorf("Case %d: Expected %d params, got: %d", i+1, len(v.params), len(receivedParams))
+		}
+	}
+}
+
+func TestPushBatched(t *testing.T) {
+	var numberOfPosts int32
+	var numberOfParams int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var receivedParams []ParamToAgent
+		json.NewDecoder(r.Body).Decode(&receivedParams)
+
+		atomic.AddInt32(&numberOfParams, int32(len(receivedParams)))
+		if atomic.AddInt32(&numberOfPosts, 1) == 2 {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL})
+
+	result := PushBatched(newSampleParams(2500), "fping", 1000)
+	err := result.Err()
+
+	if n := atomic.LoadInt32(&numberOfPosts); n != 3 {
+		t.Errorf("Expected 3 POSTs, got: %d", n)
+	}
+	if n := atomic.LoadInt32(&numberOfParams); n != 2500 {
+		t.Errorf("Expected 2500 params to be pushed, got: %d", n)
+	}
+
+	var statusErr *PushStatusError
+	if !errors.As(err, &statusErr) || !strings.Contains(err.Error(), "Chunk #2") {
+		t.Errorf("Expected error of chunk #2, got: %v", err)
+	}
+
+	if result.Chunks != 3 || result.Succeeded != 2 || result.Failed != 1 || len(result.Errors) != 1 {
+		t.Fatalf("Expected 2/3 chunks delivered, got: %+v", result)
+	}
+
+	var chunkErr *PushChunkError
+	if !errors.As(result.Errors[0], &chunkErr) || chunkErr.Index != 2 || chunkErr.Start != 1000 || chunkErr.End != 2000 {
+		t.Errorf("Expected error of chunk #2([1000:2000]), got: %v", result.Errors[0])
+	}
+}
+
+func TestPushWithMaxBodyBytes(t *testing.T) {
+	var numberOfPosts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numberOfPosts, 1)
+	}))
+	defer server.Close()
+
+	body, _ := marshalJSONParams(newSampleParams(10))
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushMaxBodyBytes: len(body) - 1, PushRetries: 3})
+
+	before := PushStats()
+	err := Push(newSampleParams(10), "fping")
+	if !errors.Is(err, ErrPushBodyTooLarge) {
+		t.Errorf("Expected ErrPushBodyTooLarge, got: %v", err)
+	}
+	if n := atomic.LoadInt32(&numberOfPosts); n != 0 {
+		t.Errorf("Expected no POST, got: %d", n)
+	}
+
+	after := PushStats()
+	if n := after.OversizedCount - before.OversizedCount; n != 1 {
+		t.Errorf("Expected 1 oversized push, got: %d", n)
+	}
+	if n := after.DroppedParams - before.DroppedParams; n != 10 {
+		t.Errorf("Expected 10 dropped params, got: %d", n)
+	}
+	if PushCircuitState() != CircuitClosed {
+		t.Errorf("Expected oversized push not to affect circuit breaker")
+	}
+
+	// The batched push splits the params
+	if err := PushBatched(newSampleParams(10), "fping", 10).Err(); err != nil {
+		t.Errorf("Batched push has error: %v", err)
+	}
+	if n := atomic.LoadInt32(&numberOfPosts); n != 2 {
+		t.Errorf("Expected 2 POSTs of split params, got: %d", n)
+	}
+}
+
+func TestPushWithContext(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushRetries: 3, PushRetryInterval: 1000})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	err := PushWithContext(ctx, []ParamToAgent{{Metric: "nqm-fping", Step: 60}}, "fping")
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected error of context, got: %v", err)
+	}
+	if elapsed := time.Since(startTime); elapsed > 500*time.Millisecond {
+		t.Errorf("Push should be cancelled in time, elapsed: %v", elapsed)
+	}
+}
+
+func TestPushWithFailover(t *testing.T) {
+	var firstPosts, secondPosts int32
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&firstPosts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer first.Close()
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&secondPosts, 1)
+	}))
+	defer second.Close()
+
+	setPushConfig(&AgentConfig{PushURLs: []string{first.URL, second.URL}})
+
+	if err := Push([]ParamToAgent{{Metric: "nqm-fping", Step: 60}}, "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+
+	if atomic.LoadInt32(&firstPosts) != 1 || atomic.LoadInt32(&secondPosts) != 1 {
+		t.Errorf("Expected the second target to be used. First: %d. Second: %d", firstPosts, secondPosts)
+	}
+}
+
+func TestPushWithRoundRobin(t *testing.T) {
+	var posts [2]int32
+	var servers []*httptest.Server
+	for i := range posts {
+		counter := &posts[i]
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(counter, 1)
+		}))
+		defer server.Close()
+		servers = append(servers, server)
+	}
+
+	setPushConfig(&AgentConfig{PushURLs: []string{servers[0].URL, servers[1].URL}, PushRoundRobin: true})
+
+	for i := 0; i < 4; i++ {
+		if err := Push([]ParamToAgent{{Metric: "nqm-fping", Step: 60}}, "fping"); err != nil {
+			t.Fatalf("Push has error: %v", err)
+		}
+	}
+
+	if atomic.LoadInt32(&posts[0]) != 2 || atomic.LoadInt32(&posts[1]) != 2 {
+		t.Errorf("Expected pushes to be spread evenly, got: %v", posts)
+	}
+}
+
+func TestPushWithHeaders(t *testing.T) {
+	testCases := []*struct {
+		token                 string
+		expectedAuthorization string
+	}{
+		{"secret-token", "Bearer secret-token"},
+		{"", ""},
+	}
+
+	for i, testCase := range testCases {
+		var receivedHeader http.Header
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedHeader = r.Header
+		}))
+
+		setPushConfig(&AgentConfig{
+			PushURL:     server.URL,
+			PushToken:   testCase.token,
+			PushHeaders: map[string]string{"X-Agent-Group": "idc-1"},
+		})
+
+		if err := Push([]ParamToAgent{{Metric: "nqm-fping", Step: 60}}, "fping"); err != nil {
+			t.Fatalf("[%d] Push has error: %v", i+1, err)
+		}
+		server.Close()
+
+		if _, ok := receivedHeader["Authorization"]; testCase.expectedAuthorization == "" && ok {
+			t.Errorf("[%d] Expected no Authorization header, got: %q", i+1, receivedHeader.Get("Authorization"))
+		}
+		if auth := receivedHeader.Get("Authorization"); auth != testCase.expectedAuthorization {
+			t.Errorf("[%d] Expected Authorization: %q, got: %q", i+1, testCase.expectedAuthorization, auth)
+		}
+		if group := receivedHeader.Get("X-Agent-Group"); group != "idc-1" {
+			t.Errorf("[%d] Expected X-Agent-Group: idc-1, got: %q", i+1, group)
+		}
+	}
+}
+
+func TestPushWithContentTypeAndPath(t *testing.T) {
+	testCases := []*struct {
+		format              string
+		contentType         string
+		path                string
+		expectedContentType string
+		expectedPath        string
+	}{
+		{"", "", "", "application/json; charset=UTF-8", "/"},
+		{"", "application/vnd.nqm+json", "/v1/push", "application/vnd.nqm+json", "/v1/push"},
+		{PushFormatMsgpack, "  ", "v1/push", "application/x-msgpack", "/v1/push"},
+	}
+
+	for i, testCase := range testCases {
+		var receivedContentType, receivedPath string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedContentType = r.Header.Get("Content-Type")
+			receivedPath = r.URL.Path
+		}))
+
+		setPushConfig(&AgentConfig{
+			PushURL:         server.URL + "/",
+			PushFormat:      testCase.format,
+			PushContentType: testCase.contentType,
+			PushPath:        testCase.path,
+		})
+
+		if err := Push(newSampleParams(1), "fping"); err != nil {
+			t.Fatalf("[%d] Push has error: %v", i+1, err)
+		}
+		server.Close()
+
+		if receivedContentType != testCase.expectedContentType {
+			t.Errorf("[%d] Expected Content-Type: %q, got: %q", i+1, testCase.expectedContentType, receivedContentType)
+		}
+		if receivedPath != testCase.expectedPath {
+			t.Errorf("[%d] Expected path: %q, got: %q", i+1, testCase.expectedPath, receivedPath)
+		}
+	}
+
+	setPushConfig(&AgentConfig{PushURL: "http://127.0.0.1:1", PushContentType: "not a/valid;;type"})
+	if err := Push(newSampleParams(1), "fping"); err == nil || !strings.Contains(err.Error(), "Invalid content type") {
+		t.Errorf("Expected error of invalid content type, got: %v", err)
+	}
+}
+
+func TestPushRaw(t *testing.T) {
+	testCases := []*struct {
+		body                []byte
+		contentType         string
+		expectedContentType string
+	}{
+		{[]byte(`[{"metric":"nqm-fping","step":60}]`), "", "application/json; charset=UTF-8"},
+		{[]byte{0x91, 0x80}, "application/x-msgpack", "application/x-msgpack"},
+	}
+
+	for i, testCase := range testCases {
+		var receivedBody []byte
+		var receivedHeader http.Header
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			receivedHeader = r.Header
+			receivedBody, _ = ioutil.ReadAll(r.Body)
+		}))
+
+		setPushConfig(&AgentConfig{PushURL: server.URL, PushGzip: true})
+
+		if err := PushRaw(bytes.NewReader(testCase.body), testCase.contentType, "fping"); err != nil {
+			t.Fatalf("[%d] PushRaw has error: %v", i+1, err)
+		}
+		server.Close()
+
+		if !bytes.Equal(receivedBody, testCase.body) {
+			t.Errorf("[%d] Expected body: %q, got: %q", i+1, testCase.body, receivedBody)
+		}
+		if contentType := receivedHeader.Get("Content-Type"); contentType != testCase.expectedContentType {
+			t.Errorf("[%d] Expected Content-Type: %q, got: %q", i+1, testCase.expectedContentType, contentType)
+		}
+		if encoding := receivedHeader.Get("Content-Encoding"); encoding != "" {
+			t.Errorf("[%d] Expected no Content-Encoding, got: %q", i+1, encoding)
+		}
+	}
+}
+
+func TestPushRawWithRetries(t *testing.T) {
+	var attempts int32
+	var receivedBodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		receivedBodies = append(receivedBodies, string(body))
+
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushRetries: 1, PushMaxBodyBytes: 16})
+
+	if err := PushRaw(strings.NewReader(`[]`), "", "fping"); err != nil {
+		t.Fatalf("PushRaw has error: %v", err)
+	}
+	if !reflect.DeepEqual(receivedBodies, []string{"[]", "[]"}) {
+		t.Errorf("Expected the body to be re-sent, got: %q", receivedBodies)
+	}
+
+	if err := PushRaw(strings.NewReader(strings.Repeat("x", 17)), "", "fping"); !errors.Is(err, ErrPushBodyTooLarge) {
+		t.Errorf("Expected ErrPushBodyTooLarge, got: %v", err)
+	}
+	if atomic.LoadInt32(&attempts) != 2 {
+		t.Errorf("Expected no attempt for oversized body, got: %d attempts", atomic.LoadInt32(&attempts))
+	}
+}
+
+func TestPushStats(t *testing.T) {
+	var numberOfPosts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&numberOfPosts, 1)
+		// The 1st post is failed and the 2nd one(retry) is succeeded, all of the following posts are failed
+		if n != 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushRetries: 1})
+
+	before := PushStats()
+
+	if err := Push([]ParamToAgent{{Metric: "nqm-fping", Step: 60}}, "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+	if err := Push(newSampleParams(3), "fping"); err == nil {
+		t.Fatalf("Expected error of push")
+	}
+
+	after := PushStats()
+	expected := PushStatistics{
+		SuccessCount:  before.SuccessCount + 1,
+		FailureCount:  before.FailureCount + 1,
+		DroppedParams: before.DroppedParams,
+
+		RateLimitedCount: before.RateLimitedCount,
+		OversizedCount:   before.OversizedCount,
+
+		EmptySkippedCount: before.EmptySkippedCount,
+
+		MarshalCount: before.MarshalCount + 2,
+		MarshalTime:  after.MarshalTime,
+		HTTPCount:    before.HTTPCount + 4,
+		HTTPTime:     after.HTTPTime,
+	}
+	if after != expected {
+		t.Errorf("Expected stats: %+v, got: %+v", expected, after)
+	}
+}
+
+func TestPushWithEmptyParams(t *testing.T) {
+	var numberOfPosts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numberOfPosts, 1)
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL})
+
+	before := PushStats()
+
+	for _, params := range [][]ParamToAgent{nil, {}} {
+		if err := Push(params, "fping"); err != nil {
+			t.Errorf("Push of empty params has error: %v", err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&numberOfPosts); n != 0 {
+		t.Errorf("Expected no POST for empty params, got: %d POST(s)", n)
+	}
+
+	after := PushStats()
+	if skipped := after.EmptySkippedCount - before.EmptySkippedCount; skipped != 2 {
+		t.Errorf("Expected 2 skipped pushes, got: %d", skipped)
+	}
+	if after.HTTPCount != before.HTTPCount || after.SuccessCount != before.SuccessCount {
+		t.Errorf("Expected no HTTP request and success to be counted, got: %+v", after)
+	}
+}
+
+func TestPushOrLogWithEmptyParams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL})
+
+	logOutput := &bytes.Buffer{}
+	log.SetOutput(logOutput)
+	defer log.SetOutput(os.Stderr)
+
+	PushOrLog(nil, "fping")
+
+	logged := logOutput.String()
+	if strings.Contains(logged, "succeeded") {
+		t.Errorf("The skipped push is logged as success: %s", logged)
+	}
+	if !strings.Contains(logged, "skipped(no params)") {
+		t.Errorf("The skipped push is not logged: %s", logged)
+	}
+}
+
+func TestPushMarshalTime(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushGzip: true, PushMarshalWarnTime: 1})
+
+	logOutput := &bytes.Buffer{}
+	log.SetOutput(logOutput)
+	defer log.SetOutput(os.Stderr)
+
+	before := PushStats()
+	if err := Push(newSampleParams(100000), "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+	after := PushStats()
+
+	if after.MarshalCount != before.MarshalCount+1 || after.MarshalTime <= before.MarshalTime {
+		t.Errorf("Expected the time of marshalling to be recorded. Before: %+v, after: %+v", before, after)
+	}
+	if after.HTTPCount != before.HTTPCount+1 || after.HTTPTime-before.HTTPTime < 20*time.Millisecond {
+		t.Errorf("Expected the time of HTTP to be recorded. Before: %+v, after: %+v", before, after)
+	}
+	if !strings.Contains(logOutput.String(), "Building body of 100000 param(s) takes") {
+		t.Errorf("Expected warning of slow marshalling, got: %s", logOutput.String())
+	}
+}
+
+func TestPushWithInvalidParams(t *testing.T) {
+	var receivedParams []ParamToAgent
+	var numberOfPosts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numberOfPosts, 1)
+		json.NewDecoder(r.Body).Decode(&receivedParams)
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL})
+
+	params := []ParamToAgent{
+		{Metric: "nqm-fping", Value: "12.5", Step: 60},
+		{Metric: "", Value: "1", Step: 60},
+		{Metric: "nqm-fping", Value: math.NaN(), Step: 60},
+		{Metric: "nqm-fping", Value: "+Inf", Step: 60},
+		{Metric: "nqm-fping", Value: "1", Step: 0},
+		{Metric: "nqm-tcpping", Value: 3, Step: 60},
+	}
+
+	if err := Push(params, "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+	if len(receivedParams) != 2 || receivedParams[0].Metric != "nqm-fping" || receivedParams[1].Metric != "nqm-tcpping" {
+		t.Errorf("Expected only valid params to be pushed, got: %v", receivedParams)
+	}
+
+	// All of the params are invalid, nothing is posted
+	err := Push(params[1:5], "fping")
+	if err == nil {
+		t.Errorf("Expected error for all invalid params")
+	}
+	if n := atomic.LoadInt32(&numberOfPosts); n != 1 {
+		t.Errorf("Expected 1 POST, got: %d", n)
+	}
+}
+
+func TestPushWithDedup(t *testing.T) {
+	var receivedParams []ParamToAgent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&receivedParams)
+	}))
+	defer server.Close()
+
+	params := []ParamToAgent{
+		{Endpoint: "agent-1", Metric: "nqm-fping", Tags: "a=1", Timestamp: 1500000000, Value: "1", Step: 60},
+		{Endpoint: "agent-1", Metric: "nqm-tcpping", Tags: "a=1", Timestamp: 1500000000, Value: "2", Step: 60},
+		{Endpoint: "agent-1", Metric: "nqm-fping", Tags: "a=1", Timestamp: 1500000000, Value: "3", Step: 60},
+	}
+
+	// Dedup is off by default
+	setPushConfig(&AgentConfig{PushURL: server.URL})
+	if err := Push(params, "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+	if len(receivedParams) != 3 {
+		t.Errorf("Expected 3 params without dedup, got: %v", receivedParams)
+	}
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushDedup: true})
+	if err := Push(params, "fping"); err != nil {
+		t.Fatalf("Push has error: %v", err)
+	}
+
+	expected := []ParamToAgent{params[2], params[1]}
+	if !reflect.DeepEqual(receivedParams, expected) {
+		t.Errorf("Expected: %v, got: %v", expected, receivedParams)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestPushWithTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, caPem, 0644); err != nil {
+		t.Fatalf("Cannot write CA file: %v", err)
+	}
+
+	// Without the CA, the certificate of server cannot be verified
+	setPushConfig(&AgentConfig{PushURL: server.URL})
+	if err := Push(newSampleParams(1), "fping"); err == nil {
+		t.Errorf("Expected error of unknown certificate authority")
+	}
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushTLS: &PushTLSConfig{CAFile: caFile}})
+	if err := InitPushClient(); err != nil {
+		t.Fatalf("InitPushClient has error: %v", err)
+	}
+	if err := Push(newSampleParams(1), "fping"); err != nil {
+		t.Errorf("Push has error: %v", err)
+	}
+}
+
+func TestInitPushClientWithInvalidTLS(t *testing.T) {
+	invalidFile := filepath.Join(t.TempDir(), "invalid.pem")
+	ioutil.WriteFile(invalidFile, []byte("not a certificate"), 0644)
+
+	testCases := []*PushTLSConfig{
+		{CAFile: filepath.Join(t.TempDir(), "not-existing.pem")},
+		{CAFile: invalidFile},
+		{CertFile: invalidFile, KeyFile: invalidFile},
+	}
+
+	for i, testCase := range testCases {
+		setPushConfig(&AgentConfig{PushTLS: testCase})
+
+		if err := InitPushClient(); err == nil {
+			t.Errorf("[%d] Expected error of InitPushClient", i+1)
+		}
+	}
+}
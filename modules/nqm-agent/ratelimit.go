@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// The token bucket holding at most one token, which is refilled by the rate
+type tokenBucket struct {
+	lock sync.Mutex
+
+	rate     float64
+	interval time.Duration
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	return &tokenBucket{
+		rate:     rate,
+		interval: time.Duration(float64(time.Second) / rate),
+		tokens:   1,
+		last:     time.Now(),
+	}
+}
+
+// Takes a token from the bucket
+//
+// If block is true, the token is reserved and the duration to wait for it is returned.
+// Otherwise, false is returned if there is no available token.
+func (b *tokenBucket) take(block bool) (time.Duration, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	b.tokens += float64(now.Sub(b.last)) / float64(b.interval)
+	if b.tokens > 1 {
+		b.tokens = 1
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	if !block {
+		return 0, false
+	}
+
+	wait := time.Duration((1 - b.tokens) * float64(b.interval))
+	b.tokens--
+	return wait, true
+}
+
+var (
+	pushLimiter     *tokenBucket
+	pushLimiterLock sync.Mutex
+)
+
+// Gets the limiter of push, which is re-built if the rate in configuration is changed
+//
+// The nil value is returned if the push is unlimited.
+func getPushLimiter() *tokenBucket {
+	rate := Config().Agent.PushRateLimit
+
+	pushLimiterLock.Lock()
+	defer pushLimiterLock.Unlock()
+
+	if rate <= 0 {
+		pushLimiter = nil
+		return nil
+	}
+	if pushLimiter == nil || pushLimiter.rate != rate {
+		pushLimiter = newTokenBucket(rate)
+	}
+
+	return pushLimiter
+}
+
+// Waits for the permission of push by the rate limit of configuration
+//
+// False is returned if the push should be dropped, which depends on PushRateLimitBehavior.
+func waitPushLimit(ctx context.Context) (bool, error) {
+	limiter := getPushLimiter()
+	if limiter == nil {
+		return true, nil
+	}
+
+	wait, ok := limiter.take(Config().Agent.PushRateLimitBehavior != OverflowDrop)
+	if !ok {
+		return false, nil
+	}
+	if wait <= 0 {
+		return true, nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-timer.C:
+		return true, nil
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPushWithRateLimitBlock(t *testing.T) {
+	var numberOfPosts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numberOfPosts, 1)
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushRateLimit: 20})
+
+	startTime := time.Now()
+
+	// The first push is permitted immediately, the following 10 pushes take 0.5 second
+	var wg sync.WaitGroup
+	for i := 0; i < 11; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Push(newSampleParams(1), "fping"); err != nil {
+				t.Errorf("Push has error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(startTime)
+	if elapsed < 450*time.Millisecond || elapsed > 1500*time.Millisecond {
+		t.Errorf("Expected the burst to take about 0.5 second, elapsed: %v", elapsed)
+	}
+	if n := atomic.LoadInt32(&numberOfPosts); n != 11 {
+		t.Errorf("Expected 11 POSTs, got: %d", n)
+	}
+}
+
+func TestPushWithRateLimitDrop(t *testing.T) {
+	var numberOfPosts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numberOfPosts, 1)
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushRateLimit: 1, PushRateLimitBehavior: OverflowDrop})
+
+	before := PushStats()
+
+	numberOfDropped := 0
+	for i := 0; i < 5; i++ {
+		err := Push(newSampleParams(2), "fping")
+		if errors.Is(err, ErrPushRateLimited) {
+			numberOfDropped++
+		} else if err != nil {
+			t.Errorf("Push has error: %v", err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&numberOfPosts); n != 1 || numberOfDropped != 4 {
+		t.Errorf("Expected 1 POST and 4 dropped pushes, got: %d POST(s), %d dropped push(es)", n, numberOfDropped)
+	}
+
+	after := PushStats()
+	if after.RateLimitedCount-before.RateLimitedCount != 4 {
+		t.Errorf("Unexpected stats. Before: %+v. After: %+v", before, after)
+	}
+}
+
+func TestPushOrLogWithRateLimitDrop(t *testing.T) {
+	var numberOfPosts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numberOfPosts, 1)
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{
+		PushURL: server.URL, PushRateLimit: 1, PushRateLimitBehavior: OverflowDrop,
+		SpoolDir: t.TempDir(),
+	})
+
+	before := PushStats()
+	for i := 0; i < 3; i++ {
+		PushOrLog(newSampleParams(2), "fping")
+	}
+
+	if n := atomic.LoadInt32(&numberOfPosts); n != 1 {
+		t.Errorf("Expected 1 POST, got: %d POST(s)", n)
+	}
+	if files, _ := listSpool(); len(files) != 0 {
+		t.Errorf("Expected the rate-limited params to be dropped instead of spooled, got: %v", files)
+	}
+	if dropped := PushStats().DroppedParams - before.DroppedParams; dropped != 4 {
+		t.Errorf("Expected 4 dropped params, got: %d", dropped)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultSpoolMaxFiles       = 100
+	defaultSpoolReplayInterval = 60 * time.Second
+	spoolFileSuffix            = ".json"
+)
+
+// Saves the params of failed push into spool directory and drops
+// the oldest files if the number or total size of files is more than the limit
+//
+// The params of evicted files are counted as dropped params.
+//
+// The name of file is "<unix nano>-<util>.json", so the files are sorted by time.
+func spoolParams(params []ParamToAgent, util string) error {
+	spoolDir := Config().Agent.SpoolDir
+
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(spoolDir, 0755); err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("%019d-%s%s", time.Now().UnixNano(), util, spoolFileSuffix)
+	tmpFile := filepath.Join(spoolDir, "."+fileName)
+	if err := ioutil.WriteFile(tmpFile, body, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpFile, filepath.Join(spoolDir, fileName)); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+
+	if err := evictSpool(); err != nil {
+		log.Errorln("[ spool ] Error on evicting spooled files:", err)
+	}
+
+	return nil
+}
+
+func evictSpool() error {
+	maxFiles := Config().Agent.SpoolMaxFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultSpoolMaxFiles
+	}
+
+	files, err := listSpool()
+	if err != nil {
+		return err
+	}
+
+	var totalBytes int64
+	sizes := make([]int64, len(files))
+	for i, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+
+		sizes[i] = info.Size()
+		totalBytes += sizes[i]
+	}
+
+	maxBytes := Config().Agent.SpoolMaxBytes
+	for len(files) > maxFiles || (maxBytes > 0 && totalBytes > maxBytes && len(files) > 1) {
+		log.Warnln("[ spool ] Dropping the oldest spooled file:", files[0])
+		numberOfParams := numberOfSpooledParams(files[0])
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		atomic.AddUint64(&pushDroppedParams, uint64(numberOfParams))
+
+		totalBytes -= sizes[0]
+		files, sizes = files[1:], sizes[1:]
+	}
+
+	return nil
+}
+
+// Gets the number of params in spooled file, 0 if the file cannot be read
+func numberOfSpooledParams(file string) int {
+	body, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0
+	}
+
+	var params []json.RawMessage
+	if err := json.Unmarshal(body, &params); err != nil {
+		return 0
+	}
+
+	return len(params)
+}
+
+// Lists the spooled files, the oldest one is the first
+func listSpool() ([]string, error) {
+	spoolDir := Config().Agent.SpoolDir
+
+	infos, err := ioutil.ReadDir(spoolDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var files []string
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, spoolFileSuffix) {
+			continue
+		}
+		files = append(files, filepath.Join(spoolDir, name))
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// Replays the spooled files periodically, it is blocked forever
+//
+// Nothing is done if the spool directory is not set.
+func ReplaySpool() {
+	if Config().Agent.SpoolDir == "" {
+		return
+	}
+
+	interval := Config().Agent.SpoolReplayInterval * time.Second
+	if interval <= 0 {
+		interval = defaultSpoolReplayInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := replaySpoolOnce(); err != nil {
+			log.Println("[ spool ] Error on replaying:", err)
+		}
+	}
+}
+
+// Pushes the spooled files from the oldest one and deletes them on success
+//
+// The replaying stops at the first push failed with retryable error, the file is kept for the next replaying.
+// The file failed with non-retryable error(e.g. 4xx) is deleted, then the replaying continues with the next file.
+func replaySpoolOnce() error {
+	files, err := listSpool()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		var params []ParamToAgent
+		if err := json.Unmarshal(body, &params); err != nil {
+			log.Warnln("[ spool ] Dropping the malformed file:", file, "Error:", err)
+			os.Remove(file)
+			continue
+		}
+
+		if err := Push(params, spoolUtilName(file)); err != nil {
+			if isRetryablePushError(err) {
+				return err
+			}
+
+			log.Warnln("[ spool ] Dropping the file failed with non-retryable error:", file, "Error:", err)
+			if err := os.Remove(file); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+		log.Println("[ spool ] Replaying", file, "...succeeded")
+	}
+
+	return nil
+}
+
+func spoolUtilName(file string) string {
+	name := strings.TrimSuffix(filepath.Base(file), spoolFileSuffix)
+	if i := strings.Index(name, "-"); i >= 0 {
+		return name[i+1:]
+	}
+
+	return name
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPushOrLogWithSpool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	spoolDir := filepath.Join(t.TempDir(), "spool")
+	setPushConfig(&AgentConfig{PushURL: server.URL, SpoolDir: spoolDir})
+
+	PushOrLog([]ParamToAgent{{Metric: "nqm-fping", Step: 60}}, "fping")
+
+	files, err := listSpool()
+	if err != nil {
+		t.Fatalf("List spool has error: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 spooled file, got: %v", files)
+	}
+	if util := spoolUtilName(files[0]); util != "fping" {
+		t.Errorf("Expected util of spooled file: fping, got: %s", util)
+	}
+}
+
+func TestPushOrLogWithNonRetryableError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, SpoolDir: t.TempDir()})
+
+	// 4xx response
+	PushOrLog([]ParamToAgent{{Metric: "nqm-fping", Step: 60}}, "fping")
+	// All of the params are invalid
+	PushOrLog([]ParamToAgent{{Metric: "", Step: 60}}, "fping")
+
+	if files, _ := listSpool(); len(files) != 0 {
+		t.Errorf("Expected nothing to be spooled, got: %v", files)
+	}
+}
+
+func TestPushOrLogWithOversizedBody(t *testing.T) {
+	var numberOfPosts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numberOfPosts, 1)
+	}))
+	defer server.Close()
+
+	params := newSampleParams(1)
+	body, _ := marshalJSONParams(params)
+	setPushConfig(&AgentConfig{PushURL: server.URL, PushMaxBodyBytes: len(body) - 1, SpoolDir: t.TempDir()})
+
+	before := PushStats()
+	PushOrLog(params, "fping")
+
+	if files, _ := listSpool(); len(files) != 0 {
+		t.Errorf("Expected the oversized params to be dropped instead of spooled, got: %v", files)
+	}
+	if n := atomic.LoadInt32(&numberOfPosts); n != 0 {
+		t.Errorf("Expected no POST, got: %d", n)
+	}
+	if n := PushStats().OversizedCount - before.OversizedCount; n != 1 {
+		t.Errorf("Expected 1 oversized push, got: %d", n)
+	}
+}
+
+func TestReplaySpool(t *testing.T) {
+	var failed int32 = 1
+	var receivedPosts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failed) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		atomic.AddInt32(&receivedPosts, 1)
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, SpoolDir: t.TempDir()})
+
+	PushOrLog([]ParamToAgent{{Metric: "nqm-fping", Step: 60}}, "fping")
+	PushOrLog([]ParamToAgent{{Metric: "nqm-tcpping", Step: 60}}, "tcpping")
+
+	// Replaying is failed, the files are kept
+	if err := replaySpoolOnce(); err == nil {
+		t.Errorf("Expected error of replaying")
+	}
+	if files, _ := listSpool(); len(files) != 2 {
+		t.Fatalf("Expected 2 spooled files, got: %v", files)
+	}
+
+	atomic.StoreInt32(&failed, 0)
+
+	if err := replaySpoolOnce(); err != nil {
+		t.Fatalf("Replay has error: %v", err)
+	}
+	if files, _ := listSpool(); len(files) != 0 {
+		t.Errorf("Expected spool to be empty, got: %v", files)
+	}
+	if n := atomic.LoadInt32(&receivedPosts); n != 2 {
+		t.Errorf("Expected 2 replayed POSTs, got: %d", n)
+	}
+}
+
+func TestSpoolEviction(t *testing.T) {
+	setPushConfig(&AgentConfig{SpoolDir: t.TempDir(), SpoolMaxFiles: 3})
+
+	var spooled []string
+	for i := 0; i < 5; i++ {
+		if err := spoolParams([]ParamToAgent{{Metric: "nqm-fping", Step: 60}}, "fping"); err != nil {
+			t.Fatalf("Spool has error: %v", err)
+		}
+
+		files, _ := listSpool()
+		spooled = append(spooled, files[len(files)-1])
+	}
+
+	files, err := listSpool()
+	if err != nil {
+		t.Fatalf("List spool has error: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 spooled files, got: %v", files)
+	}
+	for i, file := range files {
+		if file != spooled[i+2] {
+			t.Errorf("Expected the newest files to be kept. [%d] Expected: %s, got: %s", i, spooled[i+2], file)
+		}
+	}
+}
+
+func TestReplaySpoolWithNonRetryableError(t *testing.T) {
+	var receivedMetrics []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params []ParamToAgent
+		json.NewDecoder(r.Body).Decode(&params)
+
+		if params[0].Metric == "nqm-bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		receivedMetrics = append(receivedMetrics, params[0].Metric)
+	}))
+	defer server.Close()
+
+	setPushConfig(&AgentConfig{PushURL: server.URL, SpoolDir: t.TempDir()})
+
+	for _, metric := range []string{"nqm-bad", "nqm-fping"} {
+		if err := spoolParams([]ParamToAgent{{Metric: metric, Step: 60}}, "fping"); err != nil {
+			t.Fatalf("Spool has error: %v", err)
+		}
+	}
+
+	// The file failed with 4xx is dropped, the following one is replayed
+	if err := replaySpoolOnce(); err != nil {
+		t.Fatalf("Replay has error: %v", err)
+	}
+	if files, _ := listSpool(); len(files) != 0 {
+		t.Errorf("Expected spool to be empty, got: %v", files)
+	}
+	if len(receivedMetrics) != 1 || receivedMetrics[0] != "nqm-fping" {
+		t.Errorf("Expected the file after the dropped one to be replayed, got: %v", receivedMetrics)
+	}
+}
+
+func TestSpoolEvictionByBytes(t *testing.T) {
+	setPushConfig(&AgentConfig{SpoolDir: t.TempDir()})
+
+	params := []ParamToAgent{{Metric: "nqm-fping", Step: 60}}
+	if err := spoolParams(params, "fping"); err != nil {
+		t.Fatalf("Spool has error: %v", err)
+	}
+	files, _ := listSpool()
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatalf("Stat has error: %v", err)
+	}
+
+	setPushConfig(&AgentConfig{SpoolDir: Config().Agent.SpoolDir, SpoolMaxBytes: info.Size()*2 + info.Size()/2})
+	for i := 0; i < 4; i++ {
+		if err := spoolParams(params, "fping"); err != nil {
+			t.Fatalf("Spool has error: %v", err)
+		}
+	}
+
+	if files, _ := listSpool(); len(files) != 2 {
+		t.Errorf("Expected 2 spooled files within the size, got: %v", files)
+	}
+}
+
+func TestDroppedParamsOfSpool(t *testing.T) {
+	var failed int32 = 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failed) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	spoolDir := t.TempDir()
+	tests := []struct {
+		config          *AgentConfig
+		recovered       bool
+		expectedDropped uint64
+	}{
+		// Spooled and replayed
+		{&AgentConfig{PushURL: server.URL, SpoolDir: spoolDir}, true, 0},
+		// Not spooled
+		{&AgentConfig{PushURL: server.URL}, false, 4},
+		// Evicted from spool
+		{&AgentConfig{PushURL: server.URL, SpoolDir: spoolDir, SpoolMaxFiles: 1}, false, 2},
+	}
+
+	for i, v := range tests {
+		atomic.StoreInt32(&failed, 1)
+		setPushConfig(v.config)
+
+		before := PushStats()
+
+		PushOrLog(newSampleParams(2), "fping")
+		PushOrLog(newSampleParams(2), "fping")
+		if v.recovered {
+			atomic.StoreInt32(&failed, 0)
+			if err := replaySpoolOnce(); err != nil {
+				t.Fatalf("Case %d: Replay has error: %v", i+1, err)
+			}
+		}
+
+		if dropped := PushStats().DroppedParams - before.DroppedParams; dropped != v.expectedDropped {
+			t.Errorf("Case %d: Expected %d dropped params, got: %d", i+1, v.expectedDropped, dropped)
+		}
+	}
+}